@@ -5,36 +5,29 @@ import (
 	"microgit/utils"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/spf13/cobra"
 )
 
-// updateIndex writes or updates the index file with path -> hash
-func updateIndex(filePath, hash string) error {
-	indexPath := filepath.Join(utils.DEFAULT_PATH, "index")
-	existing := ""
-
-	if data, err := os.ReadFile(indexPath); err == nil {
-		existing = string(data)
-	}
+var (
+	addAll   bool
+	addForce bool
+)
 
-	lines := strings.Split(existing, "\n")
-	found := false
-	for i, line := range lines {
-		if strings.HasPrefix(line, filePath+" ") {
-			lines[i] = filePath + " " + hash
-			found = true
-			break
-		}
+// updateIndex sets the index entry of one path to hash. Staging a path
+// marks its merge conflict resolved.
+func updateIndex(filePath, hash string) error {
+	index, err := readIndex()
+	if err != nil && !os.IsNotExist(err) {
+		return err
 	}
 
-	if !found {
-		lines = append(lines, filePath+" "+hash)
+	path := filepath.ToSlash(filepath.Clean(filePath))
+	index[path] = hash
+	if err := writeIndex(index); err != nil {
+		return err
 	}
-
-	newIndex := strings.Trim(strings.Join(lines, "\n"), "\n")
-	return os.WriteFile(indexPath, []byte(newIndex), 0644)
+	return resolveConflicts(path)
 }
 
 func stageFile(path, fileName string) error {
@@ -44,11 +37,9 @@ func stageFile(path, fileName string) error {
 		return nil
 	}
 
-	// Calculate hash
-	hash := utils.HashContent(content)
-
-	// Write object
-	if err := utils.WriteObject(hash, content); err != nil {
+	// Write object and calculate its hash
+	hash, err := utils.WriteObject(utils.BLOB_OBJECT, content)
+	if err != nil {
 		fmt.Printf("Error writing object for '%s': %v\n", fileName, err)
 		return nil
 	}
@@ -64,6 +55,44 @@ func stageFile(path, fileName string) error {
 	return nil
 }
 
+// stageDeletions drops every index entry selected by the pathspecs whose
+// file no longer exists, so the next save records the deletion. It returns
+// how many entries were dropped.
+func stageDeletions(specs ...string) (int, error) {
+	index, err := readIndex()
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	var deleted []string
+	for _, path := range sortedKeys(index) {
+		for _, spec := range specs {
+			if !matchPathspec(path, spec) {
+				continue
+			}
+			if _, err := os.Lstat(path); os.IsNotExist(err) {
+				deleted = append(deleted, path)
+				delete(index, path)
+			}
+			break
+		}
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+
+	if err := writeIndex(index); err != nil {
+		return 0, err
+	}
+	if err := resolveConflicts(deleted...); err != nil {
+		return 0, err
+	}
+	for _, path := range deleted {
+		fmt.Printf("Removed %s\n", path)
+	}
+	return len(deleted), nil
+}
+
 // addCmd represents the add command
 var addCmd = &cobra.Command{
 	Use:   "add [files...]",
@@ -73,31 +102,38 @@ var addCmd = &cobra.Command{
 Usage:
   microgit add <file1> [file2 ...]  - Stage specific files
   microgit add .                    - Stage all files in current directory
+  microgit add -A                   - Stage every change, including deletions
 
 The add command will:
 1. Calculate a SHA-256 hash of the file content
 2. Store the file content in the objects directory
 3. Update the index with the file path and corresponding hash
 
-Files in the .microgit/ and .git/ directories are automatically ignored.`,
+Adding a tracked file that has been deleted stages its deletion, and
+"add ." and "add -A" stage the deletion of every tracked file that is gone.
+
+Files in the .microgit/ and .git/ directories are automatically ignored, and
+so are untracked files matched by .microgitignore files, .microgit/info/exclude
+or the core.excludesFile file. Naming an ignored file explicitly adds it only
+with --force.`,
 
 	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 && addAll {
+			args = []string{"."}
+		}
 		if len(args) == 0 {
 			fmt.Println("Error: No files specified")
 			return
 		}
 
 		if args[0] == "." {
-			err := filepath.WalkDir(".", func(path string, file os.DirEntry, err error) error {
-				if err != nil {
-					return err
-				}
-
-				if file.IsDir() || strings.HasPrefix(path, utils.DEFAULT_PATH) || strings.HasPrefix(path, ".git/") {
-					return nil
-				}
+			if _, err := stageDeletions("."); err != nil {
+				fmt.Printf("Error staging deletions: %v\n", err)
+				return
+			}
 
-				return stageFile(path, file.Name())
+			err := walkWorkingTree(func(path string) error {
+				return stageFile(path, filepath.Base(path))
 			})
 			if err != nil {
 				fmt.Printf("Error reading directory: %v\n", err)
@@ -106,7 +142,41 @@ Files in the .microgit/ and .git/ directories are automatically ignored.`,
 			return
 		}
 
+		ignore, err := loadIgnoreRules()
+		if err != nil {
+			fmt.Printf("Error reading ignore files: %v\n", err)
+			return
+		}
+		index, err := readIndex()
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Error reading index: %v\n", err)
+			return
+		}
+
 		for _, file := range args {
+			// A tracked file or directory that is gone stages its deletion
+			if _, err := os.Lstat(file); os.IsNotExist(err) {
+				removed, err := stageDeletions(filepath.ToSlash(filepath.Clean(file)))
+				if err != nil {
+					fmt.Printf("Error staging deletion of '%s': %v\n", file, err)
+					continue
+				}
+				if removed > 0 {
+					continue
+				}
+			}
+
+			// Ignored files are only tracked when asked for explicitly
+			if !addForce {
+				if info, err := os.Stat(file); err == nil {
+					path := filepath.ToSlash(filepath.Clean(file))
+					if _, tracked := index[path]; !tracked && ignore.ignored(path, info.IsDir()) {
+						fmt.Printf("Error: '%s' is ignored; use --force to add it anyway\n", file)
+						continue
+					}
+				}
+			}
+
 			err := stageFile(file, file)
 			if err != nil {
 				fmt.Printf("Error staging file: %v", err)
@@ -119,13 +189,6 @@ Files in the .microgit/ and .git/ directories are automatically ignored.`,
 func init() {
 	rootCmd.AddCommand(addCmd)
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// addCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// addCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	addCmd.Flags().BoolVarP(&addAll, "all", "A", false, "Stage all changes, including deleted files")
+	addCmd.Flags().BoolVarP(&addForce, "force", "f", false, "Add files even if they are ignored")
 }
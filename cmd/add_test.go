@@ -3,8 +3,6 @@ package cmd
 import (
 	"microgit/utils"
 	"os"
-	"path/filepath"
-	"strings"
 	"testing"
 )
 
@@ -36,6 +34,61 @@ func TestHashContent(t *testing.T) {
 	}
 }
 
+func TestObjectFormat(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	content := []byte("hello world")
+	hash, err := utils.WriteObject(utils.BLOB_OBJECT, content)
+	if err != nil {
+		t.Fatalf("WriteObject failed: %v", err)
+	}
+
+	t.Run("hash covers header and content", func(t *testing.T) {
+		if hash != utils.HashContent([]byte("blob 11\x00hello world")) {
+			t.Errorf("unexpected object hash %s", hash)
+		}
+		if hash == utils.HashObject(utils.TREE_OBJECT, content) {
+			t.Errorf("expected different kinds to hash differently")
+		}
+	})
+
+	t.Run("read returns kind and payload", func(t *testing.T) {
+		kind, got, err := utils.ReadObject(hash)
+		if err != nil {
+			t.Fatalf("ReadObject failed: %v", err)
+		}
+		if kind != utils.BLOB_OBJECT {
+			t.Errorf("kind = %s, want %s", kind, utils.BLOB_OBJECT)
+		}
+		if string(got) != string(content) {
+			t.Errorf("content = %q, want %q", got, content)
+		}
+	})
+
+	t.Run("blobs are not save points", func(t *testing.T) {
+		if _, err := readCommit(hash); err == nil {
+			t.Error("Expected error reading a blob as a save point, got nil")
+		}
+	})
+}
+
 func TestAddCmd(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir, err := os.MkdirTemp("", "microgit-test-*")
@@ -67,21 +120,37 @@ func TestAddCmd(t *testing.T) {
 	addCmd.Run(nil, []string{"test.txt"})
 
 	// Verify the object was created
-	hash := utils.HashContent(testContent)
-	objectPath := filepath.Join(utils.DEFAULT_PATH, "objects", hash)
+	hash := utils.HashObject(utils.BLOB_OBJECT, testContent)
+	objectPath := utils.ObjectPath(hash)
 	if _, err := os.Stat(objectPath); os.IsNotExist(err) {
 		t.Errorf("object file was not created at %s", objectPath)
 	}
 
 	// Verify the index was updated
-	indexPath := filepath.Join(utils.DEFAULT_PATH, "index")
-	content, err := os.ReadFile(indexPath)
+	index, err := readIndex()
 	if err != nil {
 		t.Fatalf("error reading index file: %v", err)
 	}
 
-	expected := "test.txt " + hash
-	if strings.TrimSpace(string(content)) != expected {
-		t.Errorf("index file content mismatch, got %s, want %s", content, expected)
+	if len(index) != 1 || index["test.txt"] != hash {
+		t.Errorf("index content mismatch, got %v, want test.txt %s", index, hash)
+	}
+
+	// Paths with spaces, or that start with another path, keep their own entries
+	os.WriteFile("test", []byte("prefix"), 0644)
+	os.WriteFile("test.txt extra", []byte("spaced"), 0644)
+	addCmd.Run(nil, []string{"test", "test.txt extra", "./test.txt"})
+
+	index, err = readIndex()
+	if err != nil {
+		t.Fatalf("error reading index file: %v", err)
+	}
+	want := map[string]string{
+		"test":           utils.HashObject(utils.BLOB_OBJECT, []byte("prefix")),
+		"test.txt":       hash,
+		"test.txt extra": utils.HashObject(utils.BLOB_OBJECT, []byte("spaced")),
+	}
+	if !sameFiles(index, want) {
+		t.Errorf("index content mismatch, got %v, want %v", index, want)
 	}
 }
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	branchDelete bool
+	branchRename bool
+)
+
+func createBranch(name, startPoint string) error {
+	if err := validBranchName(name); err != nil {
+		return err
+	}
+	if refExists(branchRef(name)) {
+		return fmt.Errorf("branch '%s' already exists", name)
+	}
+
+	hash, err := resolveRevision(startPoint)
+	if err != nil {
+		return err
+	}
+	if _, err := readCommit(hash); err != nil {
+		return err
+	}
+
+	return updateRef(branchRef(name), hash)
+}
+
+func deleteBranch(name string) (string, error) {
+	if err := validBranchName(name); err != nil {
+		return "", err
+	}
+	if !refExists(branchRef(name)) {
+		return "", fmt.Errorf("branch '%s' not found", name)
+	}
+	if name == currentBranch() {
+		return "", fmt.Errorf("cannot delete the current branch '%s'", name)
+	}
+
+	hash := readRef(branchRef(name))
+	return hash, deleteRef(branchRef(name))
+}
+
+func renameBranch(oldName, newName string) error {
+	if err := validBranchName(oldName); err != nil {
+		return err
+	}
+	if err := validBranchName(newName); err != nil {
+		return err
+	}
+	if refExists(branchRef(newName)) {
+		return fmt.Errorf("branch '%s' already exists", newName)
+	}
+
+	current := currentBranch()
+	hash := readRef(branchRef(oldName))
+
+	// The current branch may not have any save points yet
+	if !refExists(branchRef(oldName)) && oldName != current {
+		return fmt.Errorf("branch '%s' not found", oldName)
+	}
+
+	if hash != "" {
+		if err := updateRef(branchRef(newName), hash); err != nil {
+			return err
+		}
+		if err := deleteRef(branchRef(oldName)); err != nil {
+			return err
+		}
+	}
+
+	if oldName == current {
+		return attachHead(newName)
+	}
+	return nil
+}
+
+// branchCmd represents the branch command
+var branchCmd = &cobra.Command{
+	Use:   "branch",
+	Short: "List, create, delete or rename branches",
+	Long: `Manage branches: independent lines of save points.
+
+Usage:
+  microgit branch                       - List branches, marking the current one
+  microgit branch <name> [start]        - Create a branch at HEAD or at the start revision
+  microgit branch -d <name>             - Delete a branch
+  microgit branch -m [old] <new>        - Rename a branch (the current one by default)
+
+Branches are stored in .microgit/refs/heads/<name>. Saving advances only
+the current branch.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		switch {
+		case branchDelete:
+			if len(args) == 0 {
+				fmt.Println("Error: No branch specified")
+				return
+			}
+			for _, name := range args {
+				hash, err := deleteBranch(name)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					continue
+				}
+				fmt.Printf("Deleted branch %s (was %s)\n", name, hash)
+				warnOrphaned(hash)
+			}
+
+		case branchRename:
+			var oldName, newName string
+			switch len(args) {
+			case 1:
+				oldName, newName = currentBranch(), args[0]
+			case 2:
+				oldName, newName = args[0], args[1]
+			default:
+				fmt.Println("Usage: microgit branch -m [old] <new>")
+				return
+			}
+			if oldName == "" {
+				fmt.Println("Error: HEAD is detached; name the branch to rename")
+				return
+			}
+			if err := renameBranch(oldName, newName); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			fmt.Printf("Renamed branch %s to %s\n", oldName, newName)
+
+		case len(args) > 0:
+			startPoint := "HEAD"
+			if len(args) > 1 {
+				startPoint = args[1]
+			}
+			if err := createBranch(args[0], startPoint); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			fmt.Printf("Created branch %s\n", args[0])
+
+		default:
+			branches, err := listBranches()
+			if err != nil {
+				fmt.Printf("Error listing branches: %v\n", err)
+				return
+			}
+
+			current := currentBranch()
+			if current == "" {
+				fmt.Printf("* (HEAD detached at %s)\n", getHead())
+			}
+			for _, name := range branches {
+				marker := " "
+				if name == current {
+					marker = "*"
+				}
+				fmt.Printf("%s %s\n", marker, name)
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(branchCmd)
+
+	branchCmd.Flags().BoolVarP(&branchDelete, "delete", "d", false, "Delete the named branches")
+	branchCmd.Flags().BoolVarP(&branchRename, "move", "m", false, "Rename a branch")
+}
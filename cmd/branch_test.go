@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"microgit/utils"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBranches(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	defer func() { branchDelete, branchRename = false, false }()
+
+	initCmd.Run(nil, nil)
+
+	if err := os.WriteFile("temp.txt", []byte("testing"), 0644); err != nil {
+		t.Fatalf("WriteFile failed %v", err)
+	}
+	addCmd.Run(nil, []string{"temp.txt"})
+	saveCmd.Run(nil, []string{"on main"})
+	mainTip := readRef(branchRef(DEFAULT_BRANCH))
+
+	t.Run("create branch", func(t *testing.T) {
+		branchCmd.Run(nil, []string{"feature"})
+
+		if got := readRef(branchRef("feature")); got != mainTip {
+			t.Errorf("Expected feature to start at %s, got %s", mainTip, got)
+		}
+		if currentBranch() != DEFAULT_BRANCH {
+			t.Errorf("Expected creating a branch not to switch to it")
+		}
+	})
+
+	t.Run("save advances only the current branch", func(t *testing.T) {
+		checkoutCmd.Run(nil, []string{"feature"})
+		if currentBranch() != "feature" {
+			t.Fatalf("Expected to be on feature, got %q", currentBranch())
+		}
+
+		if err := os.WriteFile("temp.txt", []byte("feature work"), 0644); err != nil {
+			t.Fatalf("WriteFile failed %v", err)
+		}
+		addCmd.Run(nil, []string{"temp.txt"})
+		saveCmd.Run(nil, []string{"on feature"})
+
+		if readRef(branchRef(DEFAULT_BRANCH)) != mainTip {
+			t.Errorf("Expected %s to stay at %s", DEFAULT_BRANCH, mainTip)
+		}
+		if readRef(branchRef("feature")) == mainTip {
+			t.Errorf("Expected feature to advance")
+		}
+		if getHead() != readRef(branchRef("feature")) {
+			t.Errorf("Expected HEAD to resolve to the feature tip")
+		}
+	})
+
+	t.Run("checkout branch restores its files", func(t *testing.T) {
+		checkoutCmd.Run(nil, []string{DEFAULT_BRANCH})
+
+		content, err := os.ReadFile("temp.txt")
+		if err != nil {
+			t.Fatalf("ReadFile failed %v", err)
+		}
+		if string(content) != "testing" {
+			t.Errorf("Expected main's content, got %q", content)
+		}
+		if currentBranch() != DEFAULT_BRANCH {
+			t.Errorf("Expected to be on %s, got %q", DEFAULT_BRANCH, currentBranch())
+		}
+	})
+
+	t.Run("rename branch", func(t *testing.T) {
+		branchRename = true
+		branchCmd.Run(nil, []string{"feature", "topic"})
+		branchRename = false
+
+		if refExists(branchRef("feature")) || !refExists(branchRef("topic")) {
+			t.Errorf("Expected feature to be renamed to topic")
+		}
+	})
+
+	t.Run("delete branch", func(t *testing.T) {
+		branchDelete = true
+		branchCmd.Run(nil, []string{DEFAULT_BRANCH})
+		branchCmd.Run(nil, []string{"topic"})
+		branchDelete = false
+
+		if !refExists(branchRef(DEFAULT_BRANCH)) {
+			t.Errorf("Expected the current branch not to be deleted")
+		}
+		if refExists(branchRef("topic")) {
+			t.Errorf("Expected topic to be deleted")
+		}
+	})
+
+	t.Run("invalid names are rejected", func(t *testing.T) {
+		for _, name := range []string{"", "HEAD", "has space", "a..b", "-x", mainTip} {
+			if err := validBranchName(name); err == nil {
+				t.Errorf("Expected %q to be rejected", name)
+			}
+		}
+	})
+
+	t.Run("names cannot reach outside refs", func(t *testing.T) {
+		headPath := filepath.Join(utils.DEFAULT_PATH, "HEAD")
+		if _, err := deleteBranch("../../HEAD"); err == nil {
+			t.Error("Expected deleting ../../HEAD to fail")
+		}
+		if err := renameBranch("../../HEAD", "stolen"); err == nil {
+			t.Error("Expected renaming ../../HEAD to fail")
+		}
+		if _, err := os.Stat(headPath); err != nil {
+			t.Fatalf("Expected HEAD to survive: %v", err)
+		}
+
+		if err := deleteRef("refs/heads/../../HEAD"); err == nil {
+			t.Error("Expected deleteRef to refuse a path outside refs")
+		}
+		if readRef("refs/../HEAD") != "" || refExists("refs/../HEAD") {
+			t.Error("Expected readRef to refuse a path outside refs")
+		}
+	})
+}
+
+func TestMigrateRefs(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	if err := os.WriteFile("temp.txt", []byte("testing"), 0644); err != nil {
+		t.Fatalf("WriteFile failed %v", err)
+	}
+	addCmd.Run(nil, []string{"temp.txt"})
+	saveCmd.Run(nil, []string{"first"})
+	hash := getHead()
+
+	// Rewrite the references the way older versions stored them
+	headPath := filepath.Join(utils.DEFAULT_PATH, "HEAD")
+	latestPath := filepath.Join(utils.DEFAULT_PATH, "LATEST")
+	if err := os.RemoveAll(filepath.Join(utils.DEFAULT_PATH, "refs")); err != nil {
+		t.Fatalf("Failed to remove refs: %v", err)
+	}
+	if err := os.WriteFile(headPath, []byte(hash), 0644); err != nil {
+		t.Fatalf("Failed to write HEAD: %v", err)
+	}
+	if err := os.WriteFile(latestPath, []byte(hash), 0644); err != nil {
+		t.Fatalf("Failed to write LATEST: %v", err)
+	}
+
+	upgradeRepository()
+
+	if currentBranch() != DEFAULT_BRANCH {
+		t.Errorf("Expected HEAD to point at %s, got %q", DEFAULT_BRANCH, currentBranch())
+	}
+	if readRef(branchRef(DEFAULT_BRANCH)) != hash {
+		t.Errorf("Expected %s to point at the latest save point", DEFAULT_BRANCH)
+	}
+	if _, err := os.Stat(latestPath); !os.IsNotExist(err) {
+		t.Errorf("Expected LATEST to be retired")
+	}
+}
+
+func TestDetachedHead(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	save := func(content, message string) string {
+		if err := os.WriteFile("temp.txt", []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile failed %v", err)
+		}
+		addCmd.Run(nil, []string{"temp.txt"})
+		saveCmd.Run(nil, []string{message})
+		return getHead()
+	}
+
+	first := save("one", "first")
+	second := save("two", "second")
+
+	checkoutCmd.Run(nil, []string{first})
+	if currentBranch() != "" || getHead() != first {
+		t.Fatalf("Expected HEAD to be detached at %s", first)
+	}
+
+	detached := save("three", "detached work")
+
+	t.Run("saving while detached leaves branches alone", func(t *testing.T) {
+		if readRef(branchRef(DEFAULT_BRANCH)) != second {
+			t.Errorf("Expected %s to stay at %s", DEFAULT_BRANCH, second)
+		}
+		if len(orphanedSavePoints(detached)) != 0 {
+			t.Errorf("Expected the detached HEAD to keep its save point reachable")
+		}
+	})
+
+	t.Run("leaving a detached HEAD reports orphaned save points", func(t *testing.T) {
+		checkoutCmd.Run(nil, []string{"latest"})
+
+		if currentBranch() != DEFAULT_BRANCH || getHead() != second {
+			t.Errorf("Expected latest to return to %s at %s", DEFAULT_BRANCH, second)
+		}
+
+		orphaned := orphanedSavePoints(detached)
+		if len(orphaned) != 1 || orphaned[0] != detached {
+			t.Errorf("Expected %s to be orphaned, got %v", detached, orphaned)
+		}
+	})
+
+	t.Run("a branch recovers orphaned save points", func(t *testing.T) {
+		branchCmd.Run(nil, []string{"rescued", detached})
+
+		if len(orphanedSavePoints(detached)) != 0 {
+			t.Errorf("Expected the new branch to keep the save point reachable")
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkIgnoreVerbose     bool
+	checkIgnoreNonMatching bool
+	checkIgnoreNoIndex     bool
+)
+
+// checkIgnore returns the check-ignore output for each path and whether any
+// of them is ignored. Tracked paths are never ignored unless noIndex is set.
+func checkIgnore(paths []string, verbose, nonMatching, noIndex bool) ([]string, bool, error) {
+	ignore, err := loadIgnoreRules()
+	if err != nil {
+		return nil, false, err
+	}
+	index, err := readIndex()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, false, err
+	}
+
+	var lines []string
+	ignored := false
+	for _, file := range paths {
+		name := filepath.ToSlash(filepath.Clean(file))
+		info, statErr := os.Stat(file)
+		isDir := statErr == nil && info.IsDir()
+
+		pattern, err := ignore.match(name, isDir)
+		if err != nil {
+			return nil, false, err
+		}
+		if _, tracked := index[name]; tracked && !noIndex {
+			pattern = nil
+		}
+
+		if pattern != nil && !pattern.Negate {
+			ignored = true
+		}
+
+		// With -v, a matching negated pattern is shown too, as it decided
+		// that the path is not ignored
+		switch {
+		case verbose && (pattern != nil || nonMatching):
+			lines = append(lines, describeIgnore(pattern)+"\t"+file)
+		case pattern != nil && !pattern.Negate:
+			lines = append(lines, file)
+		}
+	}
+	return lines, ignored, nil
+}
+
+// checkIgnoreCmd represents the check-ignore command
+var checkIgnoreCmd = &cobra.Command{
+	Use:   "check-ignore [-v] [-n] [--no-index] <paths...>",
+	Short: "Explain whether paths are ignored",
+	Long: `Print each path that is ignored by .microgitignore files,
+.microgit/info/exclude or the core.excludesFile file.
+
+Usage:
+  microgit check-ignore <paths...>        - Print the paths that are ignored
+  microgit check-ignore -v <paths...>     - Also print the file, line and
+                                            pattern that decided each path
+  microgit check-ignore -v -n <paths...>  - Also print paths no pattern matches
+
+With -v the deciding pattern is printed as <file>:<line>:<pattern> followed
+by a tab and the path; a pattern starting with ! means the path is not
+ignored. Tracked files are never ignored, unless --no-index is given. The
+command exits with a non-zero status if no path is ignored.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			fmt.Println("Error: No path specified")
+			os.Exit(1)
+		}
+		if checkIgnoreNonMatching && !checkIgnoreVerbose {
+			fmt.Println("Error: --non-matching is only valid with --verbose")
+			os.Exit(1)
+		}
+
+		lines, ignored, err := checkIgnore(args, checkIgnoreVerbose, checkIgnoreNonMatching, checkIgnoreNoIndex)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+
+		if !ignored {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkIgnoreCmd)
+
+	checkIgnoreCmd.Flags().BoolVarP(&checkIgnoreVerbose, "verbose", "v", false, "Show the pattern that decided each path")
+	checkIgnoreCmd.Flags().BoolVarP(&checkIgnoreNonMatching, "non-matching", "n", false, "Also show paths that match no pattern")
+	checkIgnoreCmd.Flags().BoolVar(&checkIgnoreNoIndex, "no-index", false, "Check tracked files too")
+}
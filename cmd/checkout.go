@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"microgit/utils"
 	"os"
@@ -10,88 +9,177 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var checkoutForce bool
+
+// writeWorkingFile restores the blob with the given hash to path in the
+// working tree, creating parent directories as needed
+func writeWorkingFile(path, hash string) error {
+	kind, content, err := utils.ReadObject(hash)
+	if err != nil {
+		return fmt.Errorf("missing object for file %s: %w", path, err)
+	}
+	if kind != utils.BLOB_OBJECT {
+		return fmt.Errorf("object for file %s is a %s, not a blob", path, kind)
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// updateWorkingTree moves the working tree from one snapshot to another:
+// files absent from the target are removed, along with any directories that
+// leaves empty, and files that differ are rewritten. Removing first lets a
+// path change between a file and a directory.
+func updateWorkingTree(from, to map[string]string) error {
+	for path := range from {
+		if _, ok := to[path]; ok {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+
+		// Drop directories the removal left empty
+		for dir := filepath.Dir(path); dir != "."; dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+
+	for path, hash := range to {
+		if from[path] == hash {
+			if _, err := os.Stat(path); err == nil {
+				continue
+			}
+		}
+		if err := writeWorkingFile(path, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // checkoutCmd represents the checkout command
 var checkoutCmd = &cobra.Command{
 	Use:   "checkout",
-	Short: "Switch to a specific commit",
-	Long: `Switch to a specific commit in the repository history.
+	Short: "Switch to a branch or a specific commit",
+	Long: `Switch to a branch or to a specific commit in the repository history.
 
 Usage:
+  microgit checkout <branch>       - Switch to a branch
   microgit checkout <commit-hash>  - Switch to a specific commit
-  microgit checkout latest        - Switch to the most recent commit
+  microgit checkout latest         - Switch back to the most recent commit of the current branch
 
 This command will:
-1. Restore all files to their state at the specified commit
-2. Update the HEAD reference to point to the checked out commit
-3. Preserve the commit history for future operations`,
+1. Restore all files to their state at the specified commit, removing
+   tracked files the commit does not have
+2. Reset the staging area to match the commit
+3. Update HEAD to the branch, or directly to the checked out commit
+4. Preserve the commit history for future operations
+
+Checkout refuses to run when tracked files have unsaved changes or an
+untracked file would be overwritten; --force discards those changes instead.
+
+Checking out a commit rather than a branch leaves HEAD "detached". Save
+points made while detached belong to no branch; you are warned when saving
+and again when leaving them behind, along with the command that keeps them.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) == 0 {
 			fmt.Println("Error: No commit specified")
 			return
 		}
 
-		savePointHash := args[0]
-
-		if savePointHash == "latest" {
-			latestPath := filepath.Join(utils.DEFAULT_PATH, "LATEST")
-			commit, err := os.ReadFile(latestPath)
-			if err != nil {
-				fmt.Println("Error reading HEAD:", err)
-				return
-			}
+		target := args[0]
 
-			savePointHash = string(commit)
+		// Checking out a branch attaches HEAD to it; anything else detaches
+		branch := ""
+		if target == "latest" {
+			branch = latestBranch()
+		} else if refExists(branchRef(target)) {
+			branch = target
 		}
 
-		savePointPath := filepath.Join(utils.DEFAULT_PATH, "objects", savePointHash)
-		data, err := os.ReadFile(savePointPath)
+		// Save points made on a detached HEAD may only be reachable from it
+		previous := getHead()
+		wasDetached := currentBranch() == ""
+
+		// A branch wins over a tag or hash prefix of the same name
+		rev := target
+		if branch != "" {
+			rev = branchRef(branch)
+		}
+		savePointHash, err := resolveRevision(rev)
 		if err != nil {
-			fmt.Printf("savePoint %s not found", savePointHash)
+			fmt.Printf("cannot check out %s: %v\n", target, err)
 			return
 		}
 
-		var savePoint utils.SavePoint
-		if err := json.Unmarshal(data, &savePoint); err != nil {
-			fmt.Printf("invalid savePoint format: %v", err)
+		savePoint, err := readCommit(savePointHash)
+		if err != nil {
+			fmt.Printf("cannot check out %s: %v\n", savePointHash, err)
 			return
 		}
 
-		for path, hash := range savePoint.Files {
-			blobPath := filepath.Join(utils.DEFAULT_PATH, "objects", hash)
+		if readMergeHead() != "" && !checkoutForce {
+			fmt.Println("cannot check out while a merge is in progress; save it, run microgit merge --abort or use --force")
+			return
+		}
 
-			content, err := os.ReadFile(blobPath)
-			if err != nil {
-				fmt.Printf("missing object for file %s", path)
+		current, err := trackedWorkingFiles()
+		if err != nil {
+			fmt.Printf("cannot check out %s: %v\n", target, err)
+			return
+		}
+		if !checkoutForce {
+			if err := checkClean(savePoint.Files, getCommittedFiles()); err != nil {
+				fmt.Printf("cannot check out %s: %v (use --force to discard them)\n", target, err)
 				return
 			}
+		}
 
-			err = os.WriteFile(path, content, 0644)
-			if err != nil {
-				fmt.Printf("failed to restore file %s", path)
-				return
-			}
+		if err := updateWorkingTree(current, savePoint.Files); err != nil {
+			fmt.Printf("failed to restore files: %v\n", err)
+			return
+		}
+		if err := writeIndex(savePoint.Files); err != nil {
+			fmt.Printf("failed to update index: %v\n", err)
+			return
 		}
+		clearMergeState()
 
-		headPath := filepath.Join(utils.DEFAULT_PATH, "HEAD")
-		err = os.WriteFile(headPath, []byte(savePointHash), 0644)
+		if branch != "" {
+			err = attachHead(branch)
+		} else {
+			err = detachHead(savePointHash)
+		}
 		if err != nil {
-			fmt.Printf("Expected HEAD file to be created")
+			fmt.Printf("failed to update HEAD: %v\n", err)
+			return
+		}
+
+		if wasDetached && previous != savePointHash {
+			warnOrphaned(previous)
 		}
 
+		if branch != "" {
+			fmt.Printf("Switched to branch '%s'\n", branch)
+			return
+		}
 		fmt.Printf("Successfully checked out commit %s\n", savePointHash)
+		if configBool("advice.detachedHead", true) {
+			fmt.Println("You are in 'detached HEAD' state. New save points will not belong to any branch;")
+			fmt.Println("create one with 'microgit branch <name>' to keep them, or 'microgit checkout latest' to go back.")
+		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(checkoutCmd)
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// checkoutCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// checkoutCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	checkoutCmd.Flags().BoolVarP(&checkoutForce, "force", "f", false, "Discard local changes to tracked files")
 }
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSafeCheckout(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	defer func() { checkoutForce = false }()
+
+	os.WriteFile("a.txt", []byte("one\n"), 0644)
+	addCmd.Run(nil, []string{"."})
+	saveCmd.Run(nil, []string{"first"})
+	first := getHead()
+
+	os.MkdirAll("docs", 0755)
+	os.WriteFile("a.txt", []byte("two\n"), 0644)
+	os.WriteFile(filepath.Join("docs", "guide.txt"), []byte("guide\n"), 0644)
+	addCmd.Run(nil, []string{"."})
+	saveCmd.Run(nil, []string{"second"})
+	second := getHead()
+
+	t.Run("unsaved changes block the checkout", func(t *testing.T) {
+		os.WriteFile("a.txt", []byte("unsaved\n"), 0644)
+		checkoutCmd.Run(nil, []string{first})
+
+		if getHead() != second {
+			t.Errorf("Expected HEAD to stay at %s", second)
+		}
+		if content, _ := os.ReadFile("a.txt"); string(content) != "unsaved\n" {
+			t.Errorf("Expected the unsaved edit to survive, got %q", content)
+		}
+	})
+
+	t.Run("force discards them and removes stale files", func(t *testing.T) {
+		checkoutForce = true
+		checkoutCmd.Run(nil, []string{first})
+		checkoutForce = false
+
+		if getHead() != first {
+			t.Fatalf("Expected HEAD at %s, got %s", first, getHead())
+		}
+		if content, _ := os.ReadFile("a.txt"); string(content) != "one\n" {
+			t.Errorf("Expected a.txt to be restored, got %q", content)
+		}
+		if _, err := os.Stat(filepath.Join("docs", "guide.txt")); !os.IsNotExist(err) {
+			t.Errorf("Expected docs/guide.txt to be removed")
+		}
+		if _, err := os.Stat("docs"); !os.IsNotExist(err) {
+			t.Errorf("Expected the empty docs directory to be removed")
+		}
+
+		index, _ := readIndex()
+		commit, _ := readCommit(first)
+		if len(index) != len(commit.Files) || index["a.txt"] != commit.Files["a.txt"] {
+			t.Errorf("Expected the index to match %s, got %v", first, index)
+		}
+	})
+
+	t.Run("missing directories are created", func(t *testing.T) {
+		checkoutCmd.Run(nil, []string{DEFAULT_BRANCH})
+
+		content, err := os.ReadFile(filepath.Join("docs", "guide.txt"))
+		if err != nil || string(content) != "guide\n" {
+			t.Errorf("Expected docs/guide.txt to be restored, got %q (%v)", content, err)
+		}
+	})
+
+	t.Run("untracked files are not overwritten", func(t *testing.T) {
+		checkoutCmd.Run(nil, []string{first})
+		os.MkdirAll("docs", 0755)
+		os.WriteFile(filepath.Join("docs", "guide.txt"), []byte("mine\n"), 0644)
+
+		checkoutCmd.Run(nil, []string{DEFAULT_BRANCH})
+		if getHead() != first {
+			t.Errorf("Expected HEAD to stay at %s", first)
+		}
+		if content, _ := os.ReadFile(filepath.Join("docs", "guide.txt")); string(content) != "mine\n" {
+			t.Errorf("Expected the untracked file to survive, got %q", content)
+		}
+	})
+
+	t.Run("a directory can become a file and back", func(t *testing.T) {
+		os.RemoveAll("docs")
+		checkoutCmd.Run(nil, []string{DEFAULT_BRANCH})
+		rmCmd.Run(nil, []string{"docs"})
+		os.WriteFile("docs", []byte("now a file\n"), 0644)
+		addCmd.Run(nil, []string{"docs"})
+		saveCmd.Run(nil, []string{"docs as a file"})
+		third := getHead()
+
+		checkoutCmd.Run(nil, []string{second})
+		if getHead() != second {
+			t.Fatalf("Expected HEAD at %s, got %s", second, getHead())
+		}
+		if content, _ := os.ReadFile(filepath.Join("docs", "guide.txt")); string(content) != "guide\n" {
+			t.Errorf("Expected docs/guide.txt to be restored, got %q", content)
+		}
+
+		checkoutCmd.Run(nil, []string{third})
+		if getHead() != third {
+			t.Fatalf("Expected HEAD at %s, got %s", third, getHead())
+		}
+		if content, _ := os.ReadFile("docs"); string(content) != "now a file\n" {
+			t.Errorf("Expected docs to be a file again, got %q", content)
+		}
+	})
+
+	t.Run("untracked files keep their directory", func(t *testing.T) {
+		third := getHead()
+		checkoutCmd.Run(nil, []string{second})
+		os.WriteFile(filepath.Join("docs", "notes.txt"), []byte("mine\n"), 0644)
+
+		checkoutCmd.Run(nil, []string{third})
+		if getHead() != second {
+			t.Errorf("Expected HEAD to stay at %s", second)
+		}
+		if content, _ := os.ReadFile(filepath.Join("docs", "notes.txt")); string(content) != "mine\n" {
+			t.Errorf("Expected the untracked file to survive, got %q", content)
+		}
+	})
+}
@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"fmt"
+	"microgit/utils"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const DEFAULT_SYSTEM_CONFIG = "/etc/microgitconfig"
+
+var (
+	configSystem     bool
+	configGlobal     bool
+	configLocal      bool
+	configShowOrigin bool
+)
+
+var configKeyPattern = regexp.MustCompile(`^[a-z0-9-]+(\.[a-z0-9_-]+)+$`)
+
+// configFile is one layer of configuration
+type configFile struct {
+	scope string
+	path  string
+}
+
+// configFiles returns every configuration layer, lowest precedence first:
+// the system file, the user's ~/.microgitconfig and the repository's own
+// .microgit/config. MICROGIT_SYSTEM_CONFIG and MICROGIT_GLOBAL_CONFIG
+// override where the first two are read from.
+func configFiles() []configFile {
+	system := os.Getenv("MICROGIT_SYSTEM_CONFIG")
+	if system == "" {
+		system = DEFAULT_SYSTEM_CONFIG
+	}
+
+	global := os.Getenv("MICROGIT_GLOBAL_CONFIG")
+	if global == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			global = filepath.Join(home, ".microgitconfig")
+		}
+	}
+
+	files := []configFile{{scope: "system", path: system}}
+	if global != "" {
+		files = append(files, configFile{scope: "global", path: global})
+	}
+	return append(files, configFile{scope: "local", path: utils.ConfigPath()})
+}
+
+// loadConfig merges every configuration layer. It also returns the scope
+// each key was taken from.
+func loadConfig() (map[string]string, map[string]string, error) {
+	config := make(map[string]string)
+	origins := make(map[string]string)
+
+	for _, file := range configFiles() {
+		values, err := utils.ReadConfigFile(file.path)
+		if err != nil {
+			return config, origins, err
+		}
+		for key, value := range values {
+			config[key] = value
+			origins[key] = file.scope
+		}
+	}
+	return config, origins, nil
+}
+
+// configString returns the value of key, or fallback if it is not set
+func configString(key, fallback string) string {
+	config, _, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring configuration: %v\n", err)
+	}
+	if value, ok := config[strings.ToLower(key)]; ok {
+		return value
+	}
+	return fallback
+}
+
+// configBool returns key as a boolean (true/false, yes/no, on/off or 1/0),
+// or fallback if it is not set or not a boolean
+func configBool(key string, fallback bool) bool {
+	switch strings.ToLower(configString(key, "")) {
+	case "true", "yes", "on", "1":
+		return true
+	case "false", "no", "off", "0":
+		return false
+	}
+	return fallback
+}
+
+// configWriteFile returns the file set and unset write to: the repository
+// config unless --global or --system is given
+func configWriteFile() (configFile, error) {
+	scope := "local"
+	switch {
+	case configSystem && configGlobal:
+		return configFile{}, fmt.Errorf("--system and --global cannot be combined")
+	case configSystem:
+		scope = "system"
+	case configGlobal:
+		scope = "global"
+	}
+
+	// Writing the local file must not create a repository as a side effect
+	if scope == "local" {
+		if _, err := os.Stat(utils.DEFAULT_PATH); err != nil {
+			return configFile{}, fmt.Errorf("not a MicroGit repository; use --global or --system")
+		}
+	}
+
+	for _, file := range configFiles() {
+		if file.scope == scope {
+			return file, nil
+		}
+	}
+	return configFile{}, fmt.Errorf("no %s configuration file", scope)
+}
+
+// expandAlias replaces a leading alias in the command line with the
+// command it stands for. Aliases may refer to other aliases but never
+// shadow a built-in command.
+func expandAlias(args []string) []string {
+	for seen := map[string]bool{}; len(args) > 0 && !seen[args[0]]; {
+		name := args[0]
+		if command, _, err := rootCmd.Find([]string{name}); err == nil && command != rootCmd {
+			return args
+		}
+
+		alias := strings.Fields(configString("alias."+name, ""))
+		if len(alias) == 0 {
+			return args
+		}
+		seen[name] = true
+		args = append(alias, args[1:]...)
+	}
+	return args
+}
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Get and set configuration options",
+	Long: `Read and write configuration.
+
+Usage:
+  microgit config get <key>           - Print the value of a key
+  microgit config set <key> <value>   - Set a key
+  microgit config unset <key>         - Remove a key
+  microgit config list                - List every key and its value
+
+Configuration is read from three files, each overriding the one before:
+  system  /etc/microgitconfig (or $MICROGIT_SYSTEM_CONFIG)
+  global  ~/.microgitconfig (or $MICROGIT_GLOBAL_CONFIG)
+  local   .microgit/config in the repository
+
+set and unset change the local file unless --global or --system is given;
+list shows the merged settings, or a single file with --local, --global or
+--system. Each file holds "key = value" lines, and # starts a comment.
+
+Known keys:
+  user.name, user.email   Identity recorded on save points
+  log.format              Default format for log: full or oneline
+  advice.detachedHead     Explain detached HEAD after checkout (default true)
+  core.excludesFile       Ignore file that applies to every repository
+                          (default ~/.config/microgit/ignore)
+  alias.<name>            A command line to run as "microgit <name>"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			fmt.Println("Error: No action specified; use get, set, unset or list")
+			return
+		}
+
+		action, args := args[0], args[1:]
+		switch action {
+		case "get":
+			if len(args) != 1 {
+				fmt.Println("Usage: microgit config get <key>")
+				return
+			}
+			config, _, err := loadConfig()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			value, ok := config[strings.ToLower(args[0])]
+			if !ok {
+				os.Exit(1)
+			}
+			fmt.Println(value)
+
+		case "set":
+			if len(args) != 2 {
+				fmt.Println("Usage: microgit config set <key> <value>")
+				return
+			}
+			key := strings.ToLower(args[0])
+			if !configKeyPattern.MatchString(key) {
+				fmt.Printf("Error: invalid key %q; keys look like section.name\n", args[0])
+				return
+			}
+			file, err := configWriteFile()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			if err := utils.SetConfigValue(file.path, key, args[1]); err != nil {
+				fmt.Printf("Error writing %s: %v\n", file.path, err)
+			}
+
+		case "unset":
+			if len(args) != 1 {
+				fmt.Println("Usage: microgit config unset <key>")
+				return
+			}
+			file, err := configWriteFile()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			removed, err := utils.UnsetConfigValue(file.path, args[0])
+			if err != nil {
+				fmt.Printf("Error writing %s: %v\n", file.path, err)
+				return
+			}
+			if !removed {
+				fmt.Printf("Error: %s is not set in the %s configuration\n", args[0], file.scope)
+			}
+
+		case "list":
+			config, origins, err := loadConfig()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+
+			// A single file is listed on its own when a scope is given
+			for _, file := range configFiles() {
+				if (file.scope == "local" && configLocal) || (file.scope == "global" && configGlobal) || (file.scope == "system" && configSystem) {
+					if config, err = utils.ReadConfigFile(file.path); err != nil {
+						fmt.Printf("Error: %v\n", err)
+						return
+					}
+					for key := range config {
+						origins[key] = file.scope
+					}
+					break
+				}
+			}
+
+			for _, key := range sortedKeys(config) {
+				if configShowOrigin {
+					fmt.Printf("%s\t", origins[key])
+				}
+				fmt.Printf("%s=%s\n", key, config[key])
+			}
+
+		default:
+			fmt.Printf("Error: unknown action %q; use get, set, unset or list\n", action)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+
+	configCmd.Flags().BoolVar(&configSystem, "system", false, "Use the system configuration file")
+	configCmd.Flags().BoolVar(&configGlobal, "global", false, "Use the user's configuration file")
+	configCmd.Flags().BoolVar(&configLocal, "local", false, "List only the repository configuration file")
+	configCmd.Flags().BoolVar(&configShowOrigin, "show-origin", false, "Show which file each listed key comes from")
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"microgit/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	systemPath := filepath.Join(tempDir, "system")
+	globalPath := filepath.Join(tempDir, "global")
+	t.Setenv("MICROGIT_SYSTEM_CONFIG", systemPath)
+	t.Setenv("MICROGIT_GLOBAL_CONFIG", globalPath)
+
+	defer func() { configSystem, configGlobal, configLocal = false, false, false }()
+
+	os.WriteFile(systemPath, []byte("core.level = system\nlog.format = oneline\nadvice.detachedhead = off\n"), 0644)
+	os.WriteFile(globalPath, []byte("core.level = global\ndiff.context = 5\n"), 0644)
+
+	t.Run("layers override each other", func(t *testing.T) {
+		configCmd.Run(nil, []string{"set", "core.level", "local"})
+
+		config, origins, err := loadConfig()
+		if err != nil {
+			t.Fatalf("loadConfig failed: %v", err)
+		}
+		if config["core.level"] != "local" || origins["core.level"] != "local" {
+			t.Errorf("Expected the local file to win, got %q from %s", config["core.level"], origins["core.level"])
+		}
+		if config["log.format"] != "oneline" || origins["log.format"] != "system" {
+			t.Errorf("Expected log.format from the system file, got %q from %s", config["log.format"], origins["log.format"])
+		}
+
+		configCmd.Run(nil, []string{"unset", "core.level"})
+		if got := configString("core.level", ""); got != "global" {
+			t.Errorf("Expected the global value once the local one is unset, got %q", got)
+		}
+	})
+
+	t.Run("typed getters", func(t *testing.T) {
+		if configBool("advice.detachedHead", true) {
+			t.Errorf("Expected off to read as false")
+		}
+		if !configBool("missing.key", true) {
+			t.Errorf("Expected a missing key to fall back")
+		}
+	})
+
+	t.Run("set and unset keep comments and other keys", func(t *testing.T) {
+		configGlobal = true
+		configCmd.Run(nil, []string{"set", "user.name", "Ada"})
+		configCmd.Run(nil, []string{"set", "User.Name", "Ada Lovelace"})
+		configCmd.Run(nil, []string{"unset", "diff.context"})
+		configGlobal = false
+
+		os.WriteFile(globalPath, append([]byte("# mine\n"), mustRead(t, globalPath)...), 0644)
+		configGlobal = true
+		configCmd.Run(nil, []string{"set", "user.email", "ada@example.com"})
+		configGlobal = false
+
+		want := "# mine\ncore.level = global\nuser.name = Ada Lovelace\nuser.email = ada@example.com\n"
+		if got := string(mustRead(t, globalPath)); got != want {
+			t.Errorf("Unexpected global config:\n%s\nwant:\n%s", got, want)
+		}
+		if _, err := os.Stat(utils.ConfigPath()); err != nil {
+			t.Errorf("Expected the local config to exist: %v", err)
+		}
+	})
+
+	t.Run("invalid keys are rejected", func(t *testing.T) {
+		configCmd.Run(nil, []string{"set", "nodot", "value"})
+		if strings.Contains(string(mustRead(t, utils.ConfigPath())), "nodot") {
+			t.Errorf("Expected a key without a section to be rejected")
+		}
+	})
+
+	t.Run("aliases", func(t *testing.T) {
+		configCmd.Run(nil, []string{"set", "alias.last", "log --author Ada"})
+		configCmd.Run(nil, []string{"set", "alias.l", "last --format full"})
+		configCmd.Run(nil, []string{"set", "alias.status", "log"})
+		configCmd.Run(nil, []string{"set", "alias.loop", "loop"})
+
+		tests := []struct {
+			args, want []string
+		}{
+			{[]string{"last", "main"}, []string{"log", "--author", "Ada", "main"}},
+			{[]string{"l"}, []string{"log", "--author", "Ada", "--format", "full"}},
+			{[]string{"status"}, []string{"status"}},
+			{[]string{"loop"}, []string{"loop"}},
+			{[]string{"unknown"}, []string{"unknown"}},
+			{nil, nil},
+		}
+		for _, test := range tests {
+			got := expandAlias(test.args)
+			if strings.Join(got, " ") != strings.Join(test.want, " ") {
+				t.Errorf("expandAlias(%v) = %v, want %v", test.args, got, test.want)
+			}
+		}
+	})
+
+	t.Run("local settings need a repository", func(t *testing.T) {
+		outside := t.TempDir()
+		if err := os.Chdir(outside); err != nil {
+			t.Fatalf("Failed to change directory: %v", err)
+		}
+		defer os.Chdir(tempDir)
+
+		configCmd.Run(nil, []string{"set", "user.name", "Nobody"})
+		configCmd.Run(nil, []string{"unset", "user.name"})
+		if _, err := os.Stat(filepath.Join(outside, utils.DEFAULT_PATH)); !os.IsNotExist(err) {
+			t.Errorf("Expected no repository to be created, got %v", err)
+		}
+
+		configGlobal = true
+		configCmd.Run(nil, []string{"set", "user.name", "Somebody"})
+		configGlobal = false
+		if got := configString("user.name", ""); got != "Somebody" {
+			t.Errorf("Expected --global to work outside a repository, got %q", got)
+		}
+	})
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return data
+}
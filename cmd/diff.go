@@ -0,0 +1,519 @@
+package cmd
+
+import (
+	"fmt"
+	"microgit/utils"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffStaged     bool
+	diffContext    int
+	diffStat       bool
+	diffNumstat    bool
+	diffNameStatus bool
+)
+
+// fileChange summarises how a single path changed between two sides
+type fileChange struct {
+	// status is A (added), M (modified), D (deleted) or R (renamed)
+	status  string
+	path    string
+	oldPath string
+	// similarity is how alike a renamed file is to the original, in percent
+	similarity int
+	added      int
+	deleted    int
+	binary     bool
+}
+
+func (change fileChange) displayPath() string {
+	if change.status == "R" {
+		return change.oldPath + " => " + change.path
+	}
+	return change.path
+}
+
+// diffSide is one side of a comparison: a path -> blob hash snapshot whose
+// contents come either from the object store or from the working tree
+type diffSide struct {
+	files   map[string]string
+	working bool
+}
+
+func (side diffSide) content(path string) ([]byte, error) {
+	if _, ok := side.files[path]; !ok {
+		return nil, nil
+	}
+	if side.working {
+		return os.ReadFile(path)
+	}
+	return readBlob(side.files[path])
+}
+
+// revisionSide loads the snapshot of a save point
+func revisionSide(rev string) (diffSide, error) {
+	hash, err := resolveRevision(rev)
+	if err != nil {
+		return diffSide{}, err
+	}
+	commit, err := readCommit(hash)
+	if err != nil {
+		return diffSide{}, err
+	}
+	return diffSide{files: commit.Files}, nil
+}
+
+// workingSide returns the working tree copies of the tracked paths.
+// Untracked files are never part of a diff.
+func workingSide(tracked ...map[string]string) (diffSide, error) {
+	working, err := getWorkingFiles()
+	if err != nil {
+		return diffSide{}, err
+	}
+
+	files := make(map[string]string)
+	for _, snapshot := range tracked {
+		for path := range snapshot {
+			if hash, ok := working[path]; ok {
+				files[path] = hash
+			}
+		}
+	}
+	return diffSide{files: files, working: true}, nil
+}
+
+// diffSides resolves the command line into the two sides to compare
+func diffSides(args []string) (diffSide, diffSide, error) {
+	// The index is the snapshot the next save would record
+	staged, err := readIndex()
+	if err != nil {
+		return diffSide{}, diffSide{}, fmt.Errorf("could not read index: %w", err)
+	}
+
+	switch {
+	case len(args) == 2:
+		from, err := revisionSide(args[0])
+		if err != nil {
+			return diffSide{}, diffSide{}, err
+		}
+		to, err := revisionSide(args[1])
+		return from, to, err
+
+	case diffStaged:
+		from := diffSide{files: getCommittedFiles()}
+		if len(args) == 1 {
+			if from, err = revisionSide(args[0]); err != nil {
+				return diffSide{}, diffSide{}, err
+			}
+		}
+		return from, diffSide{files: staged}, nil
+
+	case len(args) == 1:
+		from, err := revisionSide(args[0])
+		if err != nil {
+			return diffSide{}, diffSide{}, err
+		}
+		to, err := workingSide(from.files, staged)
+		return from, to, err
+	}
+
+	to, err := workingSide(staged)
+	return diffSide{files: staged}, to, err
+}
+
+// changedPaths returns the sorted paths whose content differs between the sides
+func changedPaths(from, to diffSide) []string {
+	changed := make(map[string]bool)
+	for path, hash := range from.files {
+		if to.files[path] != hash {
+			changed[path] = true
+		}
+	}
+	for path, hash := range to.files {
+		if from.files[path] != hash {
+			changed[path] = true
+		}
+	}
+	return sortedKeys(changed)
+}
+
+// RENAME_THRESHOLD is the similarity, in percent, at which a deleted path
+// and an added one are taken to be the same file moved
+const RENAME_THRESHOLD = 50
+
+// RENAME_MAX_LINES is the length above which a file is only paired with an
+// identical one, since scoring it against every candidate would be too slow
+const RENAME_MAX_LINES = 5000
+
+// rename pairs a path that disappeared with the path its content moved to
+type rename struct {
+	oldPath    string
+	path       string
+	similarity int
+}
+
+// detectRenames pairs paths deleted between the sides with added ones.
+// Exact content matches are found by hash first; the remaining paths are
+// paired by content similarity, most similar first.
+func detectRenames(from, to diffSide) ([]rename, error) {
+	var deleted, added []string
+	for _, path := range sortedKeys(from.files) {
+		if _, ok := to.files[path]; !ok {
+			deleted = append(deleted, path)
+		}
+	}
+	for _, path := range sortedKeys(to.files) {
+		if _, ok := from.files[path]; !ok {
+			added = append(added, path)
+		}
+	}
+
+	var renames []rename
+	paired := make(map[string]bool)
+	for _, oldPath := range deleted {
+		for _, path := range added {
+			if !paired[path] && to.files[path] == from.files[oldPath] {
+				renames = append(renames, rename{oldPath: oldPath, path: path, similarity: 100})
+				paired[oldPath], paired[path] = true, true
+				break
+			}
+		}
+	}
+
+	// Only text files of a scoreable length are compared, and only with
+	// files whose length leaves them a chance to reach the threshold
+	scoreable := func(side diffSide, path string) ([]byte, int, error) {
+		content, err := side.content(path)
+		if err != nil || utils.IsBinary(content) {
+			return nil, 0, err
+		}
+		lines := len(utils.SplitLines(content))
+		if lines == 0 || lines > RENAME_MAX_LINES {
+			return nil, 0, nil
+		}
+		return content, lines, nil
+	}
+	addedLines := make(map[string]int)
+	for _, path := range added {
+		if paired[path] {
+			continue
+		}
+		_, lines, err := scoreable(to, path)
+		if err != nil {
+			return nil, err
+		}
+		addedLines[path] = lines
+	}
+
+	var candidates []rename
+	for _, oldPath := range deleted {
+		if paired[oldPath] {
+			continue
+		}
+		oldContent, oldLines, err := scoreable(from, oldPath)
+		if err != nil {
+			return nil, err
+		}
+		if oldLines == 0 {
+			continue
+		}
+		for _, path := range added {
+			newLines := addedLines[path]
+			if paired[path] || newLines == 0 || 200*min(oldLines, newLines)/(oldLines+newLines) < RENAME_THRESHOLD {
+				continue
+			}
+			newContent, err := to.content(path)
+			if err != nil {
+				return nil, err
+			}
+			if score := utils.Similarity(oldContent, newContent); score >= RENAME_THRESHOLD {
+				candidates = append(candidates, rename{oldPath: oldPath, path: path, similarity: score})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].similarity > candidates[j].similarity
+	})
+	for _, candidate := range candidates {
+		if !paired[candidate.oldPath] && !paired[candidate.path] {
+			renames = append(renames, candidate)
+			paired[candidate.oldPath], paired[candidate.path] = true, true
+		}
+	}
+	return renames, nil
+}
+
+// countLines counts the lines added and removed between two contents
+func countLines(change *fileChange, oldContent, newContent []byte) {
+	if utils.IsBinary(oldContent) || utils.IsBinary(newContent) {
+		change.binary = true
+		return
+	}
+	for _, edit := range utils.DiffLines(utils.SplitLines(oldContent), utils.SplitLines(newContent)) {
+		switch edit.Op {
+		case utils.INSERT:
+			change.added++
+		case utils.DELETE:
+			change.deleted++
+		}
+	}
+}
+
+// diffChanges lists every changed path with its status and line counts. A
+// deleted path whose content reappears, exactly or mostly, under a new path
+// is reported as a rename.
+func diffChanges(from, to diffSide) ([]fileChange, error) {
+	renames, err := detectRenames(from, to)
+	if err != nil {
+		return nil, err
+	}
+	renamedFrom := make(map[string]bool)
+	renamedTo := make(map[string]rename)
+	for _, r := range renames {
+		renamedFrom[r.oldPath] = true
+		renamedTo[r.path] = r
+	}
+
+	var changes []fileChange
+	for _, path := range changedPaths(from, to) {
+		if renamedFrom[path] {
+			continue
+		}
+
+		oldPath := path
+		change := fileChange{status: "M", path: path}
+		if r, ok := renamedTo[path]; ok {
+			oldPath = r.oldPath
+			change.status, change.oldPath, change.similarity = "R", r.oldPath, r.similarity
+		} else if _, ok := from.files[path]; !ok {
+			change.status = "A"
+		} else if _, ok := to.files[path]; !ok {
+			change.status = "D"
+		}
+
+		if change.similarity == 100 {
+			changes = append(changes, change)
+			continue
+		}
+
+		oldContent, err := from.content(oldPath)
+		if err != nil {
+			return nil, err
+		}
+		newContent, err := to.content(path)
+		if err != nil {
+			return nil, err
+		}
+		countLines(&change, oldContent, newContent)
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// formatNameStatus lists each changed path with its status letter
+func formatNameStatus(changes []fileChange) string {
+	var out strings.Builder
+	for _, change := range changes {
+		if change.status == "R" {
+			fmt.Fprintf(&out, "R%03d\t%s\t%s\n", change.similarity, change.oldPath, change.path)
+			continue
+		}
+		fmt.Fprintf(&out, "%s\t%s\n", change.status, change.path)
+	}
+	return out.String()
+}
+
+// formatNumstat lists added and deleted line counts in a form meant for scripts
+func formatNumstat(changes []fileChange) string {
+	var out strings.Builder
+	for _, change := range changes {
+		if change.binary {
+			fmt.Fprintf(&out, "-\t-\t%s\n", change.displayPath())
+			continue
+		}
+		fmt.Fprintf(&out, "%d\t%d\t%s\n", change.added, change.deleted, change.displayPath())
+	}
+	return out.String()
+}
+
+// formatStat draws a histogram of the changes per path and a summary line
+func formatStat(changes []fileChange) string {
+	const maxBarWidth = 40
+
+	nameWidth, countWidth, largest := 0, 0, 0
+	insertions, deletions := 0, 0
+	for _, change := range changes {
+		nameWidth = max(nameWidth, len(change.displayPath()))
+		largest = max(largest, change.added+change.deleted)
+		countWidth = max(countWidth, len(fmt.Sprint(change.added+change.deleted)))
+		insertions += change.added
+		deletions += change.deleted
+	}
+
+	var out strings.Builder
+	for _, change := range changes {
+		if change.binary {
+			fmt.Fprintf(&out, " %-*s | %*s\n", nameWidth, change.displayPath(), countWidth, "Bin")
+			continue
+		}
+
+		// Scale the bar down when the largest change would not fit
+		plus, minus := change.added, change.deleted
+		if largest > maxBarWidth {
+			plus = (change.added*maxBarWidth + largest - 1) / largest
+			minus = (change.deleted*maxBarWidth + largest - 1) / largest
+		}
+		line := fmt.Sprintf(" %-*s | %*d %s%s", nameWidth, change.displayPath(), countWidth, change.added+change.deleted,
+			strings.Repeat("+", plus), strings.Repeat("-", minus))
+		out.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+
+	fmt.Fprintf(&out, " %d file(s) changed, %d insertion(s)(+), %d deletion(s)(-)\n", len(changes), insertions, deletions)
+	return out.String()
+}
+
+// renameDiff formats the unified diff between oldPath on the from side and
+// path on the to side, with file headers noting the rename if they differ
+func renameDiff(r rename, from, to diffSide, context int) (string, error) {
+	oldContent, err := from.content(r.oldPath)
+	if err != nil {
+		return "", err
+	}
+	newContent, err := to.content(r.path)
+	if err != nil {
+		return "", err
+	}
+
+	oldName, newName := "a/"+r.oldPath, "b/"+r.path
+	var out strings.Builder
+	fmt.Fprintf(&out, "diff --microgit %s %s\n", oldName, newName)
+
+	if r.oldPath != r.path {
+		fmt.Fprintf(&out, "similarity index %d%%\nrename from %s\nrename to %s\n", r.similarity, r.oldPath, r.path)
+		if r.similarity == 100 {
+			return out.String(), nil
+		}
+	}
+
+	_, inOld := from.files[r.oldPath]
+	_, inNew := to.files[r.path]
+	if !inOld {
+		out.WriteString("new file\n")
+		oldName = "/dev/null"
+	}
+	if !inNew {
+		out.WriteString("deleted file\n")
+		newName = "/dev/null"
+	}
+
+	if utils.IsBinary(oldContent) || utils.IsBinary(newContent) {
+		fmt.Fprintf(&out, "Binary files %s and %s differ\n", oldName, newName)
+		return out.String(), nil
+	}
+
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	out.WriteString(utils.UnifiedDiff(oldContent, newContent, context))
+	return out.String(), nil
+}
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff [rev] [rev]",
+	Short: "Show changes between save points, the index and the working tree",
+	Long: `Show line by line changes as a unified diff.
+
+Usage:
+  microgit diff                  - Working tree changes that are not staged yet
+  microgit diff --staged         - Staged changes compared with HEAD
+  microgit diff <rev>            - Working tree compared with a save point
+  microgit diff <rev> <rev>      - Changes between two save points
+  microgit diff -U <n>           - Show n lines of context instead of 3
+
+Instead of the full patch, any of the comparisons above can be summarised:
+  --stat          - A histogram of lines added and removed per file
+  --numstat       - Added and removed line counts, tab separated, for scripts
+  --name-status   - Each changed path with A (added), M (modified),
+                    D (deleted) or R (renamed)
+
+A deleted file is shown as renamed when an added file has the same content,
+or at least half of its lines in common with it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 2 {
+			fmt.Println("Usage: microgit diff [rev] [rev]")
+			return
+		}
+		if diffContext < 0 {
+			fmt.Printf("Error: invalid number of context lines %d\n", diffContext)
+			return
+		}
+
+		from, to, err := diffSides(args)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		if diffStat || diffNumstat || diffNameStatus {
+			changes, err := diffChanges(from, to)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+
+			switch {
+			case diffNameStatus:
+				fmt.Print(formatNameStatus(changes))
+			case diffNumstat:
+				fmt.Print(formatNumstat(changes))
+			case len(changes) > 0:
+				fmt.Print(formatStat(changes))
+			}
+			return
+		}
+
+		renames, err := detectRenames(from, to)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		pairs := make(map[string]rename)
+		for _, r := range renames {
+			pairs[r.path] = r
+			pairs[r.oldPath] = rename{}
+		}
+
+		for _, path := range changedPaths(from, to) {
+			r, renamed := pairs[path]
+			if renamed && r.path == "" {
+				// Shown together with the path it was renamed to
+				continue
+			}
+			if !renamed {
+				r = rename{oldPath: path, path: path}
+			}
+
+			patch, err := renameDiff(r, from, to, diffContext)
+			if err != nil {
+				fmt.Printf("Error diffing %s: %v\n", path, err)
+				return
+			}
+			fmt.Print(patch)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().BoolVar(&diffStaged, "staged", false, "Compare the index with HEAD")
+	diffCmd.Flags().IntVarP(&diffContext, "unified", "U", 3, "Number of context lines")
+	diffCmd.Flags().BoolVar(&diffStat, "stat", false, "Show a histogram of changes per file")
+	diffCmd.Flags().BoolVar(&diffNumstat, "numstat", false, "Show machine readable added and deleted line counts")
+	diffCmd.Flags().BoolVar(&diffNameStatus, "name-status", false, "Show changed paths with their status")
+}
@@ -0,0 +1,344 @@
+package cmd
+
+import (
+	"fmt"
+	"math/rand"
+	"microgit/utils"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	// checkEdits verifies the edits rebuild both sides and are as few as a
+	// longest common subsequence allows
+	checkEdits := func(a, b []string) {
+		t.Helper()
+		edits := utils.DiffLines(a, b)
+
+		var old, new []string
+		changes := 0
+		for _, edit := range edits {
+			if edit.OldLine != len(old) || edit.NewLine != len(new) {
+				t.Fatalf("DiffLines(%q, %q): edit %+v out of place", a, b, edit)
+			}
+			if edit.Op != utils.INSERT {
+				old = append(old, edit.Text)
+			}
+			if edit.Op != utils.DELETE {
+				new = append(new, edit.Text)
+			}
+			if edit.Op != utils.EQUAL {
+				changes++
+			}
+		}
+		if strings.Join(old, "") != strings.Join(a, "") || strings.Join(new, "") != strings.Join(b, "") {
+			t.Fatalf("DiffLines(%q, %q) does not rebuild both sides", a, b)
+		}
+
+		common := make([][]int, len(a)+1)
+		for i := range common {
+			common[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					common[i][j] = common[i+1][j+1] + 1
+				} else {
+					common[i][j] = max(common[i+1][j], common[i][j+1])
+				}
+			}
+		}
+		if want := len(a) + len(b) - 2*common[0][0]; changes != want {
+			t.Fatalf("DiffLines(%q, %q) made %d changes, want %d", a, b, changes, want)
+		}
+	}
+
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		a := make([]string, random.Intn(10))
+		b := make([]string, random.Intn(10))
+		for j := range a {
+			a[j] = string(rune('a' + random.Intn(4)))
+		}
+		for j := range b {
+			b[j] = string(rune('a' + random.Intn(4)))
+		}
+		checkEdits(a, b)
+	}
+
+	// Large rewrites must not need memory for every step of the search
+	a := make([]string, 15000)
+	b := make([]string, 15000)
+	for i := range a {
+		a[i], b[i] = fmt.Sprintf("old %d\n", i), fmt.Sprintf("new %d\n", i)
+	}
+	if edits := utils.DiffLines(a, b); len(edits) != len(a)+len(b) {
+		t.Errorf("Expected %d edits for a full rewrite, got %d", len(a)+len(b), len(edits))
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		context  int
+		expected string
+	}{
+		{
+			name:     "identical content",
+			old:      "a\nb\n",
+			new:      "a\nb\n",
+			context:  3,
+			expected: "",
+		},
+		{
+			name:     "changed line with context",
+			old:      "a\nb\nc\n",
+			new:      "a\nB\nc\n",
+			context:  1,
+			expected: "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:     "insertion without context",
+			old:      "a\nb\n",
+			new:      "a\nx\nb\n",
+			context:  0,
+			expected: "@@ -1,0 +2 @@\n+x\n",
+		},
+		{
+			name:     "negative context is taken as none",
+			old:      "a\nb\nc\n",
+			new:      "a\nB\nc\n",
+			context:  -1,
+			expected: "@@ -2 +2 @@\n-b\n+B\n",
+		},
+		{
+			name:     "missing newline at end of file",
+			old:      "a\n",
+			new:      "a\nb",
+			context:  3,
+			expected: "@@ -1 +1,2 @@\n a\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			name:     "distant changes make separate hunks",
+			old:      "1\n2\n3\n4\n5\n6\n7\n8\n",
+			new:      "X\n2\n3\n4\n5\n6\n7\nY\n",
+			context:  1,
+			expected: "@@ -1,2 +1,2 @@\n-1\n+X\n 2\n@@ -7,2 +7,2 @@\n 7\n-8\n+Y\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := utils.UnifiedDiff([]byte(tt.old), []byte(tt.new), tt.context)
+			if got != tt.expected {
+				t.Errorf("UnifiedDiff() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDiffCmd(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	defer func() { diffStaged, diffContext = false, 3 }()
+
+	initCmd.Run(nil, nil)
+
+	if err := os.WriteFile("temp.txt", []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed %v", err)
+	}
+	addCmd.Run(nil, []string{"temp.txt"})
+	saveCmd.Run(nil, []string{"first"})
+	first := getHead()
+
+	if err := os.WriteFile("temp.txt", []byte("one\n2\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed %v", err)
+	}
+	addCmd.Run(nil, []string{"temp.txt"})
+	if err := os.WriteFile("temp.txt", []byte("one\n2\nthree\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed %v", err)
+	}
+
+	patch := func(args ...string) string {
+		from, to, err := diffSides(args)
+		if err != nil {
+			t.Fatalf("diffSides failed: %v", err)
+		}
+
+		var out strings.Builder
+		for _, path := range changedPaths(from, to) {
+			diff, err := renameDiff(rename{oldPath: path, path: path}, from, to, diffContext)
+			if err != nil {
+				t.Fatalf("renameDiff failed: %v", err)
+			}
+			out.WriteString(diff)
+		}
+		return out.String()
+	}
+
+	t.Run("working tree against index", func(t *testing.T) {
+		diffStaged = false
+		got := patch()
+		if !strings.Contains(got, "+three\n") || strings.Contains(got, "-two\n") {
+			t.Errorf("Expected only the unstaged change, got:\n%s", got)
+		}
+	})
+
+	t.Run("index against HEAD", func(t *testing.T) {
+		diffStaged = true
+		got := patch()
+		diffStaged = false
+		if !strings.Contains(got, "-two\n+2\n") || strings.Contains(got, "three") {
+			t.Errorf("Expected only the staged change, got:\n%s", got)
+		}
+	})
+
+	t.Run("between save points", func(t *testing.T) {
+		saveCmd.Run(nil, []string{"second"})
+		got := patch(first, "HEAD")
+		if !strings.HasPrefix(got, "diff --microgit a/temp.txt b/temp.txt\n--- a/temp.txt\n+++ b/temp.txt\n") {
+			t.Errorf("Expected file headers, got:\n%s", got)
+		}
+		if !strings.Contains(got, "-two\n+2\n") {
+			t.Errorf("Expected the saved change, got:\n%s", got)
+		}
+	})
+}
+
+func TestDiffSummaries(t *testing.T) {
+	changes := []fileChange{
+		{status: "A", path: "added.txt", added: 2},
+		{status: "D", path: "gone.txt", deleted: 1},
+		{status: "M", path: "modified.txt", added: 1, deleted: 1},
+		{status: "R", path: "new.txt", oldPath: "old.txt", similarity: 100},
+		{status: "M", path: "image.png", binary: true},
+	}
+
+	t.Run("name-status", func(t *testing.T) {
+		want := "A\tadded.txt\nD\tgone.txt\nM\tmodified.txt\nR100\told.txt\tnew.txt\nM\timage.png\n"
+		if got := formatNameStatus(changes); got != want {
+			t.Errorf("formatNameStatus() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("numstat", func(t *testing.T) {
+		want := "2\t0\tadded.txt\n0\t1\tgone.txt\n1\t1\tmodified.txt\n0\t0\told.txt => new.txt\n-\t-\timage.png\n"
+		if got := formatNumstat(changes); got != want {
+			t.Errorf("formatNumstat() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("stat", func(t *testing.T) {
+		got := formatStat(changes)
+		if !strings.Contains(got, " modified.txt       | 2 +-\n") {
+			t.Errorf("Expected an aligned histogram line, got:\n%s", got)
+		}
+		if !strings.HasSuffix(got, " 5 file(s) changed, 3 insertion(s)(+), 2 deletion(s)(-)\n") {
+			t.Errorf("Expected a summary line, got:\n%s", got)
+		}
+	})
+}
+
+func TestDiffChangesDetectsRenames(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	hashes := map[string]string{}
+	for _, content := range []string{"a\n", "b\n", "same\n"} {
+		hash, err := utils.WriteObject(utils.BLOB_OBJECT, []byte(content))
+		if err != nil {
+			t.Fatalf("WriteObject failed: %v", err)
+		}
+		hashes[content] = hash
+	}
+
+	from := diffSide{files: map[string]string{"old.txt": hashes["same\n"], "kept.txt": hashes["a\n"]}}
+	to := diffSide{files: map[string]string{"new.txt": hashes["same\n"], "kept.txt": hashes["b\n"]}}
+
+	got, err := diffChanges(from, to)
+	if err != nil {
+		t.Fatalf("diffChanges failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 changes, got %+v", got)
+	}
+	if got[0].status != "M" || got[0].path != "kept.txt" || got[0].added != 1 || got[0].deleted != 1 {
+		t.Errorf("Expected kept.txt to be modified, got %+v", got[0])
+	}
+	if got[1].status != "R" || got[1].oldPath != "old.txt" || got[1].path != "new.txt" {
+		t.Errorf("Expected old.txt to be renamed to new.txt, got %+v", got[1])
+	}
+
+	t.Run("similar content", func(t *testing.T) {
+		before, _ := utils.WriteObject(utils.BLOB_OBJECT, []byte("one\ntwo\nthree\nfour\n"))
+		after, _ := utils.WriteObject(utils.BLOB_OBJECT, []byte("one\ntwo\nthree\nfive\n"))
+		unrelated, _ := utils.WriteObject(utils.BLOB_OBJECT, []byte("something else\n"))
+
+		from := diffSide{files: map[string]string{"draft.txt": before}}
+		to := diffSide{files: map[string]string{"final.txt": after, "other.txt": unrelated}}
+
+		got, err := diffChanges(from, to)
+		if err != nil {
+			t.Fatalf("diffChanges failed: %v", err)
+		}
+		want := "R075\tdraft.txt\tfinal.txt\nA\tother.txt\n"
+		if names := formatNameStatus(got); names != want {
+			t.Errorf("formatNameStatus() = %q, want %q", names, want)
+		}
+		if got[0].added != 1 || got[0].deleted != 1 {
+			t.Errorf("Expected the rename to count the edited line, got %+v", got[0])
+		}
+	})
+
+	t.Run("large files are only paired when identical", func(t *testing.T) {
+		lines := strings.Repeat("line\n", RENAME_MAX_LINES)
+		before, _ := utils.WriteObject(utils.BLOB_OBJECT, []byte(lines+"old\n"))
+		after, _ := utils.WriteObject(utils.BLOB_OBJECT, []byte(lines+"new\n"))
+
+		from := diffSide{files: map[string]string{"big.txt": before}}
+		to := diffSide{files: map[string]string{"huge.txt": after}}
+
+		got, err := diffChanges(from, to)
+		if err != nil {
+			t.Fatalf("diffChanges failed: %v", err)
+		}
+		want := "D\tbig.txt\nA\thuge.txt\n"
+		if names := formatNameStatus(got); names != want {
+			t.Errorf("formatNameStatus() = %q, want %q", names, want)
+		}
+	})
+}
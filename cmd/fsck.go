@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"microgit/utils"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+// fsckReport collects the problems found while checking a repository
+type fsckReport struct {
+	// corrupt is keyed by object hash, or by pack name for a damaged pack
+	corrupt   map[string]string
+	missing   map[string]string
+	dangling  map[string]utils.ObjectKind
+	reachable map[string]bool
+}
+
+func newFsckReport() *fsckReport {
+	return &fsckReport{
+		corrupt:   map[string]string{},
+		missing:   map[string]string{},
+		dangling:  map[string]utils.ObjectKind{},
+		reachable: map[string]bool{},
+	}
+}
+
+func (report *fsckReport) ok() bool {
+	return len(report.corrupt) == 0 && len(report.missing) == 0
+}
+
+// checkObject reads an object that is referenced from somewhere, recording
+// it as missing or corrupt if that fails
+func (report *fsckReport) checkObject(hash, description string, want utils.ObjectKind) ([]byte, bool) {
+	report.reachable[hash] = true
+
+	if !utils.HasObject(hash) {
+		report.missing[hash] = description
+		return nil, false
+	}
+	if _, bad := report.corrupt[hash]; bad {
+		return nil, false
+	}
+
+	kind, content, err := utils.ReadObject(hash)
+	if err != nil {
+		report.corrupt[hash] = err.Error()
+		return nil, false
+	}
+	if kind != want {
+		report.corrupt[hash] = fmt.Sprintf("expected a %s (%s), found a %s", want, description, kind)
+		return nil, false
+	}
+	return content, true
+}
+
+// checkHistory walks every save point reachable from hash
+func (report *fsckReport) checkHistory(hash, referrer string) {
+	type pending struct{ hash, referrer string }
+	queue := []pending{{hash, referrer}}
+
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		hash := next.hash
+		if hash == "" || report.reachable[hash] {
+			continue
+		}
+
+		content, ok := report.checkObject(hash, "save point "+next.referrer, utils.SAVEPOINT_OBJECT)
+		if !ok {
+			continue
+		}
+
+		var savePoint utils.SavePoint
+		if err := json.Unmarshal(content, &savePoint); err != nil {
+			report.corrupt[hash] = fmt.Sprintf("invalid save point: %v", err)
+			continue
+		}
+
+		// Older save points list their files inline
+		for path, blob := range savePoint.Files {
+			report.checkObject(blob, fmt.Sprintf("blob for %s in save point %s", path, hash), utils.BLOB_OBJECT)
+		}
+		if savePoint.Tree != "" {
+			report.checkTree(savePoint.Tree, "", hash)
+		}
+
+		for _, parent := range savePoint.ParentHashes() {
+			queue = append(queue, pending{parent, "parent of " + hash})
+		}
+	}
+}
+
+// checkRef checks the object a reference points at: either a save point, or
+// an annotated tag that leads to one
+func (report *fsckReport) checkRef(hash string) {
+	for depth := 0; hash != "" && !report.reachable[hash]; depth++ {
+		kind, _, err := utils.ReadObject(hash)
+		if err != nil || kind != utils.TAG_OBJECT || depth > 10 {
+			report.checkHistory(hash, "referenced by a ref")
+			return
+		}
+
+		content, ok := report.checkObject(hash, "tag referenced by a ref", utils.TAG_OBJECT)
+		if !ok {
+			return
+		}
+		var tag utils.Tag
+		if err := json.Unmarshal(content, &tag); err != nil {
+			report.corrupt[hash] = fmt.Sprintf("invalid tag: %v", err)
+			return
+		}
+		if tag.Target == "" {
+			report.corrupt[hash] = "tag has no target"
+			return
+		}
+		hash = tag.Target
+	}
+}
+
+// checkTree verifies a tree and everything below it
+func (report *fsckReport) checkTree(hash, prefix, savePoint string) {
+	if report.reachable[hash] {
+		return
+	}
+
+	description := fmt.Sprintf("tree for /%s in save point %s", prefix, savePoint)
+	content, ok := report.checkObject(hash, description, utils.TREE_OBJECT)
+	if !ok {
+		return
+	}
+
+	var tree utils.Tree
+	if err := json.Unmarshal(content, &tree); err != nil {
+		report.corrupt[hash] = fmt.Sprintf("invalid tree: %v", err)
+		return
+	}
+
+	for _, entry := range tree.Entries {
+		entryPath := prefix + entry.Name
+		if entry.Mode == utils.DIR_MODE {
+			report.checkTree(entry.Hash, entryPath+"/", savePoint)
+			continue
+		}
+		if !report.reachable[entry.Hash] {
+			report.checkObject(entry.Hash, fmt.Sprintf("blob for %s in save point %s", entryPath, savePoint), utils.BLOB_OBJECT)
+		}
+	}
+}
+
+// runFsck checks every stored object and everything reachable from the
+// references and the index
+func runFsck() (*fsckReport, error) {
+	report := newFsckReport()
+
+	// Re-hash every stored object against its name
+	loose, err := utils.ListLooseObjects()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	for _, hash := range loose {
+		if err := utils.VerifyLooseObject(hash); err != nil {
+			report.corrupt[hash] = err.Error()
+		}
+	}
+
+	packs, err := utils.ListPacks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packs: %w", err)
+	}
+	// A damaged pack is reported along with whichever of its objects are
+	// damaged too, and the rest of the repository is still checked
+	for _, pack := range packs {
+		problems, err := utils.VerifyPack(pack)
+		if err != nil {
+			report.corrupt[pack] = err.Error()
+		}
+		for hash, problem := range problems {
+			report.corrupt[hash] = problem.Error()
+		}
+	}
+
+	for _, root := range gcRoots() {
+		report.checkRef(root)
+	}
+
+	index, err := readIndex()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read index: %w", err)
+	}
+	for path, hash := range index {
+		report.checkObject(hash, "blob for staged file "+path, utils.BLOB_OBJECT)
+	}
+
+	// Anything stored but never referenced is dangling
+	stored := loose
+	for _, pack := range packs {
+		packed, err := utils.ListPackObjects(pack)
+		if err != nil {
+			// Already reported as corrupt
+			continue
+		}
+		for _, object := range packed {
+			stored = append(stored, object.Hash)
+		}
+	}
+	for _, hash := range stored {
+		if report.reachable[hash] {
+			continue
+		}
+		if _, bad := report.corrupt[hash]; bad {
+			continue
+		}
+		kind, _, _ := utils.ReadObject(hash)
+		report.dangling[hash] = kind
+	}
+
+	return report, nil
+}
+
+func sortedKeys[V any](items map[string]V) []string {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fsckCmd represents the fsck command
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Verify the integrity of the repository",
+	Long: `Check the repository for damaged or missing objects.
+
+This command will:
+1. Re-hash every stored object and compare it with the hash it is stored under
+2. Walk the history from HEAD, every branch and every other reference
+3. Check that every tree and file referenced by each save point exists
+
+Problems are reported as:
+  corrupt   - the object or pack cannot be read or does not match its hash
+  missing   - the object is referenced but not stored
+  dangling  - the object is stored but nothing references it
+
+The command exits with a non-zero status if any object is corrupt or missing.
+Dangling objects are harmless and can be removed with microgit gc.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		report, err := runFsck()
+		if err != nil {
+			fmt.Printf("Error checking repository: %v\n", err)
+			os.Exit(1)
+		}
+
+		for _, hash := range sortedKeys(report.corrupt) {
+			fmt.Printf("corrupt %s: %s\n", hash, report.corrupt[hash])
+		}
+		for _, hash := range sortedKeys(report.missing) {
+			fmt.Printf("missing %s: %s\n", hash, report.missing[hash])
+		}
+		for _, hash := range sortedKeys(report.dangling) {
+			fmt.Printf("dangling %s %s\n", report.dangling[hash], hash)
+		}
+
+		if !report.ok() {
+			fmt.Printf("%d corrupt, %d missing, %d dangling object(s)\n", len(report.corrupt), len(report.missing), len(report.dangling))
+			os.Exit(1)
+		}
+
+		fmt.Printf("Repository is consistent (%d dangling object(s))\n", len(report.dangling))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fsckCmd)
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"microgit/utils"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFsck(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	if err := os.WriteFile("temp.txt", []byte("abandoned"), 0644); err != nil {
+		t.Fatalf("WriteFile failed %v", err)
+	}
+	addCmd.Run(nil, []string{"temp.txt"})
+	if err := os.WriteFile("temp.txt", []byte("testing"), 0644); err != nil {
+		t.Fatalf("WriteFile failed %v", err)
+	}
+	addCmd.Run(nil, []string{"temp.txt"})
+	saveCmd.Run(nil, []string{"first"})
+
+	if err := os.WriteFile("other.txt", []byte("more"), 0644); err != nil {
+		t.Fatalf("WriteFile failed %v", err)
+	}
+	addCmd.Run(nil, []string{"other.txt"})
+	saveCmd.Run(nil, []string{"second"})
+
+	abandoned := utils.HashObject(utils.BLOB_OBJECT, []byte("abandoned"))
+	blob := utils.HashObject(utils.BLOB_OBJECT, []byte("testing"))
+
+	t.Run("healthy repository", func(t *testing.T) {
+		report, err := runFsck()
+		if err != nil {
+			t.Fatalf("runFsck failed: %v", err)
+		}
+		if !report.ok() {
+			t.Errorf("Expected no problems, got corrupt=%v missing=%v", report.corrupt, report.missing)
+		}
+		if _, ok := report.dangling[abandoned]; !ok || len(report.dangling) != 1 {
+			t.Errorf("Expected only the re-staged blob to be dangling, got %v", report.dangling)
+		}
+	})
+
+	t.Run("corrupt object", func(t *testing.T) {
+		if err := os.WriteFile(utils.ObjectPath(blob), []byte("blob 3\x00bad"), 0644); err != nil {
+			t.Fatalf("Failed to corrupt object: %v", err)
+		}
+
+		report, err := runFsck()
+		if err != nil {
+			t.Fatalf("runFsck failed: %v", err)
+		}
+		if _, ok := report.corrupt[blob]; !ok {
+			t.Errorf("Expected %s to be reported corrupt, got %v", blob, report.corrupt)
+		}
+		if report.ok() {
+			t.Errorf("Expected the report to fail")
+		}
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+		if err := os.Remove(utils.ObjectPath(blob)); err != nil {
+			t.Fatalf("Failed to remove object: %v", err)
+		}
+
+		report, err := runFsck()
+		if err != nil {
+			t.Fatalf("runFsck failed: %v", err)
+		}
+		if _, ok := report.missing[blob]; !ok {
+			t.Errorf("Expected %s to be reported missing, got %v", blob, report.missing)
+		}
+	})
+}
+
+func TestFsckDamagedPack(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	if err := os.WriteFile("temp.txt", []byte("packed"), 0644); err != nil {
+		t.Fatalf("WriteFile failed %v", err)
+	}
+	addCmd.Run(nil, []string{"temp.txt"})
+	saveCmd.Run(nil, []string{"first"})
+
+	result, err := packLooseObjects()
+	if err != nil {
+		t.Fatalf("packLooseObjects failed: %v", err)
+	}
+	packed, err := utils.ListPackObjects(result.Name)
+	if err != nil {
+		t.Fatalf("ListPackObjects failed: %v", err)
+	}
+
+	// A later save keeps its objects loose
+	if err := os.WriteFile("other.txt", []byte("loose"), 0644); err != nil {
+		t.Fatalf("WriteFile failed %v", err)
+	}
+	addCmd.Run(nil, []string{"other.txt"})
+	saveCmd.Run(nil, []string{"second"})
+
+	packPath := filepath.Join(utils.DEFAULT_PATH, "objects", "pack", result.Name+".pack")
+	if err := os.WriteFile(packPath, []byte("MGPK"), 0644); err != nil {
+		t.Fatalf("Failed to damage pack: %v", err)
+	}
+
+	report, err := runFsck()
+	if err != nil {
+		t.Fatalf("runFsck failed: %v", err)
+	}
+	if _, ok := report.corrupt[result.Name]; !ok {
+		t.Errorf("Expected %s to be reported corrupt, got %v", result.Name, report.corrupt)
+	}
+	for _, object := range packed {
+		if _, ok := report.corrupt[object.Hash]; !ok {
+			t.Errorf("Expected packed object %s to be reported corrupt", object.Hash)
+		}
+	}
+	if !report.reachable[utils.HashObject(utils.BLOB_OBJECT, []byte("loose"))] {
+		t.Errorf("Expected the history to be walked despite the damaged pack")
+	}
+}
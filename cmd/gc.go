@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"microgit/utils"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	gcDryRun bool
+	gcGrace  time.Duration
+)
+
+// gcObject is an object considered for pruning
+type gcObject struct {
+	hash     string
+	size     int64
+	modified time.Time
+	packed   bool
+}
+
+// gcRoots returns every save point or annotated tag HEAD, MERGE_HEAD or a
+// reference points at. The index is handled separately because it names
+// blobs rather than save points.
+func gcRoots() []string {
+	var roots []string
+
+	if head := getHead(); head != "" {
+		roots = append(roots, head)
+	}
+
+	// An unfinished merge needs the other side for its save point
+	if mergeHead := readMergeHead(); mergeHead != "" {
+		roots = append(roots, mergeHead)
+	}
+
+	refs, _ := listRefs("refs/")
+	for _, name := range sortedKeys(refs) {
+		if refs[name] != "" {
+			roots = append(roots, refs[name])
+		}
+	}
+
+	return roots
+}
+
+// markReachable records the object and everything it references
+func markReachable(reachable map[string]bool, hash string) error {
+	if hash == "" || reachable[hash] {
+		return nil
+	}
+
+	kind, content, err := utils.ReadObject(hash)
+	if err != nil {
+		return fmt.Errorf("cannot read object %s: %w", hash, err)
+	}
+	reachable[hash] = true
+
+	switch kind {
+	case utils.SAVEPOINT_OBJECT:
+		var savePoint utils.SavePoint
+		if err := json.Unmarshal(content, &savePoint); err != nil {
+			return fmt.Errorf("invalid save point %s: %w", hash, err)
+		}
+		for _, blob := range savePoint.Files {
+			reachable[blob] = true
+		}
+		if err := markReachable(reachable, savePoint.Tree); err != nil {
+			return err
+		}
+		for _, parent := range savePoint.ParentHashes() {
+			if err := markReachable(reachable, parent); err != nil {
+				return err
+			}
+		}
+
+	case utils.TAG_OBJECT:
+		var tag utils.Tag
+		if err := json.Unmarshal(content, &tag); err != nil {
+			return fmt.Errorf("invalid tag %s: %w", hash, err)
+		}
+		if err := markReachable(reachable, tag.Target); err != nil {
+			return err
+		}
+
+	case utils.TREE_OBJECT:
+		var tree utils.Tree
+		if err := json.Unmarshal(content, &tree); err != nil {
+			return fmt.Errorf("invalid tree %s: %w", hash, err)
+		}
+		for _, entry := range tree.Entries {
+			// Blobs reference nothing, so there is no need to read them
+			if entry.Mode != utils.DIR_MODE {
+				reachable[entry.Hash] = true
+				continue
+			}
+			if err := markReachable(reachable, entry.Hash); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// reachableObjects returns every object reachable from a reference or the index
+func reachableObjects() (map[string]bool, error) {
+	reachable := make(map[string]bool)
+
+	for _, root := range gcRoots() {
+		if err := markReachable(reachable, root); err != nil {
+			return nil, err
+		}
+	}
+
+	index, err := readIndex()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read index: %w", err)
+	}
+	for _, hash := range index {
+		reachable[hash] = true
+	}
+
+	return reachable, nil
+}
+
+// collectObjects lists every loose and packed object in the repository
+func collectObjects() ([]gcObject, error) {
+	var objects []gcObject
+
+	loose, err := utils.ListLooseObjects()
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range loose {
+		info, err := utils.LooseObjectInfo(hash)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, gcObject{hash: hash, size: info.Size(), modified: info.ModTime()})
+	}
+
+	packs, err := utils.ListPacks()
+	if err != nil {
+		return nil, err
+	}
+	for _, pack := range packs {
+		info, err := utils.PackInfo(pack)
+		if err != nil {
+			return nil, err
+		}
+		packed, err := utils.ListPackObjects(pack)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range packed {
+			objects = append(objects, gcObject{hash: object.Hash, size: object.Size, modified: info.ModTime(), packed: true})
+		}
+	}
+
+	return objects, nil
+}
+
+// gcCmd represents the gc command
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove unreachable objects and pack the rest",
+	Long: `Clean up the object store.
+
+Every object reachable from HEAD, an unfinished merge, any branch or other
+reference, or the index is kept. Unreachable objects, such as blobs for
+files that were staged and later re-staged or removed, are deleted once they
+are older than the grace period; until then they stay loose. Every reachable
+object is then packed into a single pack file.
+
+Usage:
+  microgit gc                 - Prune and pack
+  microgit gc --dry-run       - List what would be removed and the bytes reclaimed
+  microgit gc --grace 1h      - Only prune objects older than one hour`,
+	Run: func(cmd *cobra.Command, args []string) {
+		reachable, err := reachableObjects()
+		if err != nil {
+			fmt.Printf("Error walking history: %v\n", err)
+			return
+		}
+
+		objects, err := collectObjects()
+		if err != nil {
+			fmt.Printf("Error listing objects: %v\n", err)
+			return
+		}
+
+		cutoff := time.Now().Add(-gcGrace)
+		keep := make(map[string]bool)
+		for _, object := range objects {
+			if reachable[object.hash] || !object.modified.Before(cutoff) {
+				keep[object.hash] = true
+			}
+		}
+
+		// An object can be stored both loose and packed; it is only pruned
+		// when no copy needs to be kept
+		var prune []gcObject
+		pruned := make(map[string]bool)
+		var reclaimed int64
+		for _, object := range objects {
+			if keep[object.hash] {
+				continue
+			}
+			prune = append(prune, object)
+			pruned[object.hash] = true
+			reclaimed += object.size
+		}
+		sort.Slice(prune, func(i, j int) bool { return prune[i].hash < prune[j].hash })
+
+		if gcDryRun {
+			for _, object := range prune {
+				fmt.Printf("Would remove %s (%d bytes)\n", object.hash, object.size)
+			}
+			fmt.Printf("Would remove %d unreachable object(s), reclaiming %d bytes\n", len(pruned), reclaimed)
+			return
+		}
+
+		for _, object := range prune {
+			if object.packed {
+				// Packed objects are dropped by leaving them out of the new pack
+				continue
+			}
+			if err := utils.RemoveLooseObject(object.hash); err != nil {
+				fmt.Printf("Error removing object %s: %v\n", object.hash, err)
+				return
+			}
+		}
+
+		// Unreachable objects still within the grace period stay loose, so
+		// their age is that of their file and they expire on a later run.
+		// Those only found in a pack are unpacked, keeping the pack's date.
+		pack := make(map[string]bool)
+		loose := make(map[string]bool)
+		for _, object := range objects {
+			if !object.packed {
+				loose[object.hash] = true
+			}
+		}
+		for _, object := range objects {
+			switch {
+			case reachable[object.hash]:
+				pack[object.hash] = true
+			case keep[object.hash] && !loose[object.hash]:
+				if err := utils.UnpackObject(object.hash, object.modified); err != nil {
+					fmt.Printf("Error unpacking object %s: %v\n", object.hash, err)
+					return
+				}
+				loose[object.hash] = true
+			}
+		}
+
+		if err := repack(pack); err != nil {
+			fmt.Printf("Error packing objects: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Removed %d unreachable object(s), reclaimed %d bytes\n", len(pruned), reclaimed)
+		fmt.Printf("Packed %d object(s)\n", len(pack))
+		if unreachable := len(keep) - len(pack); unreachable > 0 {
+			fmt.Printf("Kept %d recent unreachable object(s) loose\n", unreachable)
+		}
+	},
+}
+
+// repack writes the given objects into a single new pack, then removes the
+// old packs and the loose copies it replaces. Other loose objects are left
+// alone.
+func repack(keep map[string]bool) error {
+	oldPacks, err := utils.ListPacks()
+	if err != nil {
+		return err
+	}
+	loose, err := utils.ListLooseObjects()
+	if err != nil {
+		return err
+	}
+
+	var hashes []string
+	for hash := range keep {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	newPack := ""
+	if len(hashes) > 0 {
+		result, err := utils.WritePack(hashes)
+		if err != nil {
+			return err
+		}
+		newPack = result.Name
+	}
+
+	for _, pack := range oldPacks {
+		if pack == newPack {
+			continue
+		}
+		if err := utils.RemovePack(pack); err != nil {
+			return err
+		}
+	}
+	for _, hash := range loose {
+		if !keep[hash] {
+			continue
+		}
+		if err := utils.RemoveLooseObject(hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "List unreachable objects without removing anything")
+	gcCmd.Flags().DurationVar(&gcGrace, "grace", 14*24*time.Hour, "Only prune unreachable objects older than this")
+}
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"microgit/utils"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGcCmd(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	defer func() { gcDryRun, gcGrace = false, 14*24*time.Hour }()
+
+	initCmd.Run(nil, nil)
+
+	// Staging twice leaves the first blob unreachable
+	if err := os.WriteFile("temp.txt", []byte("first draft"), 0644); err != nil {
+		t.Fatalf("WriteFile failed %v", err)
+	}
+	addCmd.Run(nil, []string{"temp.txt"})
+	if err := os.WriteFile("temp.txt", []byte("final version"), 0644); err != nil {
+		t.Fatalf("WriteFile failed %v", err)
+	}
+	addCmd.Run(nil, []string{"temp.txt"})
+	saveCmd.Run(nil, []string{"save"})
+
+	// A blob that is staged but not saved yet must survive
+	if err := os.WriteFile("staged.txt", []byte("work in progress"), 0644); err != nil {
+		t.Fatalf("WriteFile failed %v", err)
+	}
+	addCmd.Run(nil, []string{"staged.txt"})
+
+	draft := utils.HashObject(utils.BLOB_OBJECT, []byte("first draft"))
+	final := utils.HashObject(utils.BLOB_OBJECT, []byte("final version"))
+	staged := utils.HashObject(utils.BLOB_OBJECT, []byte("work in progress"))
+
+	t.Run("recent objects survive the grace period", func(t *testing.T) {
+		gcDryRun, gcGrace = false, time.Hour
+		gcCmd.Run(nil, nil)
+
+		if !utils.HasObject(draft) {
+			t.Errorf("Expected recent unreachable object to be kept")
+		}
+	})
+
+	t.Run("dry run removes nothing", func(t *testing.T) {
+		gcDryRun, gcGrace = true, 0
+		gcCmd.Run(nil, nil)
+
+		if !utils.HasObject(draft) {
+			t.Errorf("Expected dry run to keep unreachable object")
+		}
+	})
+
+	t.Run("unreachable objects are pruned", func(t *testing.T) {
+		gcDryRun, gcGrace = false, 0
+		gcCmd.Run(nil, nil)
+
+		if utils.HasObject(draft) {
+			t.Errorf("Expected unreachable object to be removed")
+		}
+		for _, hash := range []string{final, staged, getHead()} {
+			if !utils.HasObject(hash) {
+				t.Errorf("Expected reachable object %s to be kept", hash)
+			}
+		}
+		if _, err := readCommit(getHead()); err != nil {
+			t.Errorf("Expected HEAD to stay readable: %v", err)
+		}
+	})
+
+	t.Run("kept objects are packed", func(t *testing.T) {
+		loose, err := utils.ListLooseObjects()
+		if err != nil {
+			t.Fatalf("ListLooseObjects failed: %v", err)
+		}
+		if len(loose) != 0 {
+			t.Errorf("Expected no loose objects after gc, got %d", len(loose))
+		}
+
+		packs, err := utils.ListPacks()
+		if err != nil || len(packs) != 1 {
+			t.Errorf("Expected a single pack after gc, got %v (%v)", packs, err)
+		}
+	})
+
+	t.Run("recent unreachable objects stay loose until they expire", func(t *testing.T) {
+		scratch, _ := utils.WriteObject(utils.BLOB_OBJECT, []byte("scratch"))
+
+		gcDryRun, gcGrace = false, time.Hour
+		gcCmd.Run(nil, nil)
+		if _, err := utils.LooseObjectInfo(scratch); err != nil {
+			t.Fatalf("Expected the recent object to stay loose: %v", err)
+		}
+
+		// Running gc again must not make the object look any younger
+		old := time.Now().Add(-2 * time.Hour)
+		os.Chtimes(utils.ObjectPath(scratch), old, old)
+		gcCmd.Run(nil, nil)
+		if utils.HasObject(scratch) {
+			t.Errorf("Expected the object to be pruned once it expired")
+		}
+	})
+
+	t.Run("packed unreachable objects keep the pack's age", func(t *testing.T) {
+		scratch, _ := utils.WriteObject(utils.BLOB_OBJECT, []byte("packed scratch"))
+		result, err := packLooseObjects()
+		if err != nil {
+			t.Fatalf("packLooseObjects failed: %v", err)
+		}
+		packed := time.Now().Add(-30 * time.Minute)
+		os.Chtimes(filepath.Join(utils.DEFAULT_PATH, "objects", "pack", result.Name+".pack"), packed, packed)
+
+		gcDryRun, gcGrace = false, time.Hour
+		gcCmd.Run(nil, nil)
+		info, err := utils.LooseObjectInfo(scratch)
+		if err != nil {
+			t.Fatalf("Expected the object to be unpacked: %v", err)
+		}
+		if info.ModTime().Sub(packed).Abs() > time.Second {
+			t.Errorf("Expected the unpacked object to date from %v, got %v", packed, info.ModTime())
+		}
+		if kind, content, err := utils.ReadObject(scratch); err != nil || kind != utils.BLOB_OBJECT || string(content) != "packed scratch" {
+			t.Errorf("ReadObject = %s %q %v", kind, content, err)
+		}
+	})
+
+	t.Run("the other side of an unfinished merge is kept", func(t *testing.T) {
+		saveCmd.Run(nil, []string{"save staged"})
+		branch := currentBranch()
+
+		// Make a save point that only MERGE_HEAD will refer to
+		checkoutCmd.Run(nil, []string{getHead()})
+		os.WriteFile("temp.txt", []byte("theirs"), 0644)
+		addCmd.Run(nil, []string{"temp.txt"})
+		saveCmd.Run(nil, []string{"theirs"})
+		theirs := getHead()
+		checkoutCmd.Run(nil, []string{branch})
+
+		os.WriteFile("temp.txt", []byte("ours"), 0644)
+		addCmd.Run(nil, []string{"temp.txt"})
+		saveCmd.Run(nil, []string{"ours"})
+		mergeCmd.Run(nil, []string{theirs})
+		if readMergeHead() != theirs {
+			t.Fatalf("Expected the merge to stop with a conflict")
+		}
+
+		gcDryRun, gcGrace = false, 0
+		gcCmd.Run(nil, nil)
+		if !utils.HasObject(theirs) {
+			t.Fatalf("Expected MERGE_HEAD to be kept")
+		}
+
+		os.WriteFile("temp.txt", []byte("resolved"), 0644)
+		addCmd.Run(nil, []string{"temp.txt"})
+		saveCmd.Run(nil, []string{"merge"})
+		report, err := runFsck()
+		if err != nil {
+			t.Fatalf("runFsck failed: %v", err)
+		}
+		if !report.ok() {
+			t.Errorf("Expected a healthy repository, got corrupt %v missing %v", report.corrupt, report.missing)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"microgit/utils"
+	"os"
+	"os/user"
+)
+
+// identity returns the name and email recorded for a role, "author" or
+// "committer". MICROGIT_<ROLE>_NAME and MICROGIT_<ROLE>_EMAIL take
+// precedence over the user.name and user.email settings; the name falls
+// back to the login name.
+func identity(role string) (string, string) {
+	name, email := configString("user.name", ""), configString("user.email", "")
+
+	prefix := "MICROGIT_AUTHOR_"
+	if role == "committer" {
+		prefix = "MICROGIT_COMMITTER_"
+	}
+	if value := os.Getenv(prefix + "NAME"); value != "" {
+		name = value
+	}
+	if value := os.Getenv(prefix + "EMAIL"); value != "" {
+		email = value
+	}
+
+	if name == "" {
+		if current, err := user.Current(); err == nil {
+			name = current.Username
+		}
+	}
+	return name, email
+}
+
+// signSavePoint fills in whichever of the author and committer are missing
+func signSavePoint(savePoint *utils.SavePoint) {
+	if savePoint.AuthorName == "" && savePoint.AuthorEmail == "" {
+		savePoint.AuthorName, savePoint.AuthorEmail = identity("author")
+	}
+	if savePoint.CommitterName == "" && savePoint.CommitterEmail == "" {
+		savePoint.CommitterName, savePoint.CommitterEmail = identity("committer")
+	}
+}
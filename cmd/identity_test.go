@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"microgit/utils"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIdentity(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	config := "# who saves\nuser.name = Ada Lovelace\nUser.Email = ada@example.com\n"
+	if err := os.WriteFile(utils.ConfigPath(), []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	t.Setenv("MICROGIT_AUTHOR_NAME", "")
+	t.Setenv("MICROGIT_AUTHOR_EMAIL", "")
+	t.Setenv("MICROGIT_COMMITTER_NAME", "")
+	t.Setenv("MICROGIT_COMMITTER_EMAIL", "")
+
+	os.WriteFile("temp.txt", []byte("testing"), 0644)
+	addCmd.Run(nil, []string{"temp.txt"})
+	saveCmd.Run(nil, []string{"from config"})
+
+	t.Run("identity comes from the config", func(t *testing.T) {
+		commit, err := readCommit(getHead())
+		if err != nil {
+			t.Fatalf("readCommit failed: %v", err)
+		}
+		if commit.Author() != "Ada Lovelace <ada@example.com>" || commit.Committer() != commit.Author() {
+			t.Errorf("Unexpected author %q and committer %q", commit.Author(), commit.Committer())
+		}
+	})
+
+	t.Run("environment variables take precedence", func(t *testing.T) {
+		t.Setenv("MICROGIT_AUTHOR_NAME", "Grace Hopper")
+		t.Setenv("MICROGIT_AUTHOR_EMAIL", "grace@example.com")
+
+		os.WriteFile("temp.txt", []byte("more testing"), 0644)
+		addCmd.Run(nil, []string{"temp.txt"})
+		saveCmd.Run(nil, []string{"from the environment"})
+
+		commit, _ := readCommit(getHead())
+		if commit.Author() != "Grace Hopper <grace@example.com>" {
+			t.Errorf("Unexpected author %q", commit.Author())
+		}
+		if commit.Committer() != "Ada Lovelace <ada@example.com>" {
+			t.Errorf("Unexpected committer %q", commit.Committer())
+		}
+	})
+
+	t.Run("identity is part of the hash", func(t *testing.T) {
+		savePoint := utils.SavePoint{Message: "same", Timestamp: time.Now().Format(time.RFC3339)}
+		first, _ := writeSavePointObject(savePoint)
+
+		savePoint.AuthorName = "Someone Else"
+		second, _ := writeSavePointObject(savePoint)
+		if first == second {
+			t.Errorf("Expected different authors to give different hashes")
+		}
+	})
+
+	t.Run("save points without an identity still parse", func(t *testing.T) {
+		data, _ := json.Marshal(map[string]interface{}{
+			"message":   "old",
+			"timestamp": "2024-01-01T00:00:00Z",
+			"files":     map[string]string{},
+		})
+		hash, err := utils.WriteObject(utils.SAVEPOINT_OBJECT, data)
+		if err != nil {
+			t.Fatalf("WriteObject failed: %v", err)
+		}
+
+		commit, err := readCommit(hash)
+		if err != nil {
+			t.Fatalf("readCommit failed: %v", err)
+		}
+		if commit.Author() != "" || commit.Committer() != "" {
+			t.Errorf("Expected no identity, got %q and %q", commit.Author(), commit.Committer())
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"microgit/utils"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRules decides which paths of the working tree are ignored. Patterns
+// come from, in increasing order of precedence, the core.excludesFile file,
+// .microgit/info/exclude and the .microgitignore file of every directory,
+// deeper ones overriding those above them. A directory's .microgitignore is
+// read the first time a path inside it is checked.
+type ignoreRules struct {
+	rules  utils.IgnoreRules
+	loaded map[string]bool
+	// dirs caches the pattern that decided each directory, nil if none did
+	dirs map[string]*utils.IgnorePattern
+}
+
+// globalExcludesFile returns the path of the user's own ignore file
+func globalExcludesFile() string {
+	file := configString("core.excludesFile", "")
+	if file == "" {
+		config := os.Getenv("XDG_CONFIG_HOME")
+		if config == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return ""
+			}
+			config = filepath.Join(home, ".config")
+		}
+		return filepath.Join(config, "microgit", "ignore")
+	}
+
+	if rest, ok := strings.CutPrefix(file, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			file = filepath.Join(home, rest)
+		}
+	}
+	return file
+}
+
+// loadIgnoreRules reads the ignore files that apply to the whole repository
+func loadIgnoreRules() (*ignoreRules, error) {
+	ignore := &ignoreRules{
+		loaded: make(map[string]bool),
+		dirs:   make(map[string]*utils.IgnorePattern),
+	}
+
+	sources := []string{filepath.Join(utils.DEFAULT_PATH, "info", "exclude")}
+	if global := globalExcludesFile(); global != "" {
+		sources = append([]string{global}, sources...)
+	}
+	for _, source := range sources {
+		patterns, err := utils.ReadIgnoreFile(source, "")
+		if err != nil {
+			return nil, err
+		}
+		ignore.rules = append(ignore.rules, patterns...)
+	}
+	return ignore, ignore.load("")
+}
+
+// load reads the .microgitignore file of a directory once
+func (ignore *ignoreRules) load(dir string) error {
+	if ignore.loaded[dir] {
+		return nil
+	}
+	ignore.loaded[dir] = true
+
+	patterns, err := utils.ReadIgnoreFile(filepath.Join(filepath.FromSlash(dir), utils.IGNORE_FILE), dir)
+	if err != nil {
+		return err
+	}
+	ignore.rules = append(ignore.rules, patterns...)
+	return nil
+}
+
+// match returns the pattern that decides whether a path is ignored, or nil
+// if none matches. A path inside an ignored directory is decided by the
+// pattern that ignored the directory, since nothing can re-include it.
+func (ignore *ignoreRules) match(file string, isDir bool) (*utils.IgnorePattern, error) {
+	file = filepath.ToSlash(filepath.Clean(file))
+
+	parts := strings.Split(file, "/")
+	for i := 1; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		if pattern := ignore.matchDir(dir); pattern != nil && !pattern.Negate {
+			return pattern, nil
+		}
+		if err := ignore.load(dir); err != nil {
+			return nil, err
+		}
+	}
+	return ignore.rules.Match(file, isDir), nil
+}
+
+// matchDir decides a directory. The ignore files of its parent directories
+// must already be loaded.
+func (ignore *ignoreRules) matchDir(dir string) *utils.IgnorePattern {
+	pattern, ok := ignore.dirs[dir]
+	if !ok {
+		pattern = ignore.rules.Match(dir, true)
+		ignore.dirs[dir] = pattern
+	}
+	return pattern
+}
+
+// ignored reports whether a path is ignored
+func (ignore *ignoreRules) ignored(file string, isDir bool) bool {
+	pattern, err := ignore.match(file, isDir)
+	return err == nil && pattern != nil && !pattern.Negate
+}
+
+// walkWorkingTree calls fn for every file of the working tree that is not
+// ignored, plus ignored files that are tracked anyway. The repository's own
+// directories are always skipped.
+func walkWorkingTree(fn func(file string) error) error {
+	ignore, err := loadIgnoreRules()
+	if err != nil {
+		return err
+	}
+	index, err := readIndex()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// Ignored directories are only entered when they hold tracked files
+	trackedDirs := make(map[string]bool)
+	for file := range index {
+		for dir := path.Dir(file); dir != "."; dir = path.Dir(dir) {
+			trackedDirs[dir] = true
+		}
+	}
+
+	return filepath.WalkDir(".", func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if file == "." {
+			return nil
+		}
+
+		name := filepath.ToSlash(file)
+		if entry.IsDir() {
+			if name == utils.DEFAULT_PATH || name == ".git" {
+				return filepath.SkipDir
+			}
+			if ignore.ignored(name, true) && !trackedDirs[name] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if _, tracked := index[name]; !tracked && ignore.ignored(name, false) {
+			return nil
+		}
+		return fn(name)
+	})
+}
+
+// describeIgnore explains which pattern decided a path, the way
+// check-ignore -v shows it
+func describeIgnore(pattern *utils.IgnorePattern) string {
+	if pattern == nil {
+		return "::"
+	}
+	source := filepath.ToSlash(pattern.Source)
+	return fmt.Sprintf("%s:%d:%s", source, pattern.Line, pattern.Text)
+}
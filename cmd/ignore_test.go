@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"microgit/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIgnorePatterns(t *testing.T) {
+	tests := []struct {
+		pattern, base, path string
+		isDir               bool
+		want                bool
+	}{
+		{"*.log", "", "debug.log", false, true},
+		{"*.log", "", "logs/debug.log", false, true},
+		{"*.log", "", "debug.txt", false, false},
+		{"build/", "", "build", true, true},
+		{"build/", "", "build", false, false},
+		{"build/", "", "src/build", true, true},
+		{"/build", "", "build", true, true},
+		{"/build", "", "src/build", true, false},
+		{"doc/*.txt", "", "doc/notes.txt", false, true},
+		{"doc/*.txt", "", "doc/server/arch.txt", false, false},
+		{"doc/**/*.txt", "", "doc/notes.txt", false, true},
+		{"doc/**/*.txt", "", "doc/server/arch.txt", false, true},
+		{"**/node_modules", "", "node_modules", true, true},
+		{"**/node_modules", "", "web/app/node_modules", true, true},
+		{"out/**", "", "out/a/b.o", false, true},
+		{"out/**", "", "out", true, false},
+		{".*.sw[a-p]", "", "src/.main.go.swp", false, true},
+		{"file[!0-9]", "", "file1", false, false},
+		{"file[!0-9]", "", "filex", false, true},
+		{"?.c", "", "a.c", false, true},
+		{"?.c", "", "ab.c", false, false},
+		{"\\#notes", "", "#notes", false, true},
+		{"trailing\\ ", "", "trailing ", false, true},
+		{"*.tmp", "web", "web/cache.tmp", false, true},
+		{"*.tmp", "web", "cache.tmp", false, false},
+		{"/dist", "web", "web/dist", true, true},
+		{"/dist", "web", "web/app/dist", true, false},
+	}
+
+	for _, test := range tests {
+		pattern, ok := utils.ParseIgnorePattern(test.pattern, test.base)
+		if !ok {
+			t.Errorf("ParseIgnorePattern(%q) found no pattern", test.pattern)
+			continue
+		}
+		if got := pattern.Matches(test.path, test.isDir); got != test.want {
+			t.Errorf("%q in %q matching %q (dir: %v) = %v, want %v", test.pattern, test.base, test.path, test.isDir, got, test.want)
+		}
+	}
+
+	for _, line := range []string{"", "   ", "# comment", "/"} {
+		if _, ok := utils.ParseIgnorePattern(line, ""); ok {
+			t.Errorf("Expected %q to hold no pattern", line)
+		}
+	}
+}
+
+func TestIgnoreFiles(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	configDir := t.TempDir()
+	globalIgnore := filepath.Join(configDir, "ignore")
+	t.Setenv("MICROGIT_SYSTEM_CONFIG", filepath.Join(configDir, "system"))
+	t.Setenv("MICROGIT_GLOBAL_CONFIG", filepath.Join(configDir, "global"))
+	os.WriteFile(filepath.Join(configDir, "global"), []byte("core.excludesFile = "+globalIgnore+"\n"), 0644)
+	os.WriteFile(globalIgnore, []byte("*.swp\n"), 0644)
+
+	initCmd.Run(nil, nil)
+
+	defer func() { addForce = false }()
+
+	os.WriteFile(".microgitignore", []byte("*.log\n!keep.log\nnode_modules/\n/build\n"), 0644)
+	os.WriteFile(filepath.Join(utils.DEFAULT_PATH, "info", "exclude"), []byte("secret.txt\n"), 0644)
+	os.MkdirAll(filepath.Join("web", "node_modules", "lib"), 0755)
+	os.MkdirAll(filepath.Join("web", "build"), 0755)
+	os.MkdirAll("build", 0755)
+	os.WriteFile(filepath.Join("web", ".microgitignore"), []byte("*.tmp\n!important.log\n"), 0644)
+
+	files := map[string]string{
+		"main.go":                          "main",
+		"debug.log":                        "log",
+		"keep.log":                         "kept",
+		"secret.txt":                       "secret",
+		"main.go.swp":                      "swap",
+		"build/out.bin":                    "binary",
+		"web/build/page.html":              "page",
+		"web/cache.tmp":                    "cache",
+		"web/important.log":                "important",
+		"web/node_modules/lib/index.js":    "module",
+		"web/node_modules/lib/package.log": "module log",
+	}
+	for path, content := range files {
+		os.WriteFile(filepath.FromSlash(path), []byte(content), 0644)
+	}
+
+	t.Run("add . skips ignored files", func(t *testing.T) {
+		addCmd.Run(nil, []string{"."})
+
+		want := []string{".microgitignore", "keep.log", "main.go", "web/.microgitignore", "web/build/page.html", "web/important.log"}
+		if got := sortedKeys(readIndexEntries(t)); strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("Expected the index to hold %v, got %v", want, got)
+		}
+	})
+
+	t.Run("status does not list ignored files", func(t *testing.T) {
+		working, err := getWorkingFiles()
+		if err != nil {
+			t.Fatalf("getWorkingFiles failed: %v", err)
+		}
+		for path := range working {
+			if _, tracked := readIndexEntries(t)[path]; !tracked {
+				t.Errorf("Expected %s to be ignored", path)
+			}
+		}
+	})
+
+	t.Run("ignored files are added only with --force", func(t *testing.T) {
+		addCmd.Run(nil, []string{"debug.log"})
+		if _, ok := readIndexEntries(t)["debug.log"]; ok {
+			t.Error("Expected debug.log to stay untracked")
+		}
+
+		addForce = true
+		addCmd.Run(nil, []string{"debug.log"})
+		addForce = false
+		if _, ok := readIndexEntries(t)["debug.log"]; !ok {
+			t.Error("Expected --force to track debug.log")
+		}
+
+		// Tracked files are never ignored
+		working, _ := getWorkingFiles()
+		if _, ok := working["debug.log"]; !ok {
+			t.Error("Expected tracked debug.log in the working files")
+		}
+	})
+
+	t.Run("check-ignore explains matches", func(t *testing.T) {
+		paths := []string{"main.go.swp", "secret.txt", "web/cache.tmp", "web/node_modules/lib/package.log", "logs/keep.log", "main.go", "debug.log"}
+		lines, ignored, err := checkIgnore(paths, true, true, false)
+		if err != nil {
+			t.Fatalf("checkIgnore failed: %v", err)
+		}
+		if !ignored {
+			t.Error("Expected some paths to be ignored")
+		}
+
+		want := []string{
+			filepath.ToSlash(globalIgnore) + ":1:*.swp\tmain.go.swp",
+			".microgit/info/exclude:1:secret.txt\tsecret.txt",
+			"web/.microgitignore:1:*.tmp\tweb/cache.tmp",
+			".microgitignore:3:node_modules/\tweb/node_modules/lib/package.log",
+			".microgitignore:2:!keep.log\tlogs/keep.log",
+			"::\tmain.go",
+			"::\tdebug.log",
+		}
+		if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+			t.Errorf("checkIgnore() =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+		}
+
+		lines, ignored, _ = checkIgnore([]string{"main.go", "web/important.log"}, false, false, false)
+		if ignored || len(lines) != 0 {
+			t.Errorf("Expected nothing to be ignored, got %v", lines)
+		}
+	})
+}
@@ -9,6 +9,13 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// DEFAULT_EXCLUDE is the starting content of .microgit/info/exclude
+const DEFAULT_EXCLUDE = `# Patterns of files this repository ignores that should not be shared in
+# a .microgitignore file, one per line, e.g.
+# *.swp
+# build/
+`
+
 // initCmd represents the init command
 var initCmd = &cobra.Command{
 	Use:   "init",
@@ -28,14 +35,19 @@ The repository will be initialized in a .microgit directory.`,
 
 		os.Mkdir(repoDir, 0755)
 		os.Mkdir(objectsDir, 0755)
+		// Branch tips
+		os.MkdirAll(repoDir+"/refs/heads", 0755)
 
 		// Create index and HEAD files
 		// Staging area
-		os.WriteFile(repoDir+"/index", []byte(""), 0644)
-		// Pointer to the current commit
-		os.WriteFile(repoDir+"/HEAD", []byte(""), 0644)
-		// Pointer to the latest commit
-		os.WriteFile(repoDir+"/LATEST", []byte(""), 0644)
+		utils.WriteIndex(map[string]string{})
+		// Pointer to the current branch
+		attachHead(DEFAULT_BRANCH)
+		// Patterns of files to ignore in this repository only
+		os.MkdirAll(repoDir+"/info", 0755)
+		os.WriteFile(repoDir+"/info/exclude", []byte(DEFAULT_EXCLUDE), 0644)
+		// Storage format of the objects directory
+		utils.WriteFormat(utils.CurrentFormat())
 
 		fmt.Printf("Initialized empty SCM repository in %s/\n", utils.DEFAULT_PATH)
 	},
@@ -53,10 +53,20 @@ func TestInitCommand(t *testing.T) {
 			t.Errorf("Expected HEAD file to be created")
 		}
 
-		// Check if LATEST file was created
-		latestFile := filepath.Join(utils.DEFAULT_PATH, "LATEST")
-		if _, err := os.Stat(latestFile); os.IsNotExist(err) {
-			t.Errorf("Expected LATEST file to be created")
+		// Check if HEAD points at the default branch
+		if branch := currentBranch(); branch != DEFAULT_BRANCH {
+			t.Errorf("Expected HEAD to point at branch %s, got %q", DEFAULT_BRANCH, branch)
+		}
+
+		// Check if the branch directory was created
+		headsDir := filepath.Join(utils.DEFAULT_PATH, "refs", "heads")
+		if _, err := os.Stat(headsDir); os.IsNotExist(err) {
+			t.Errorf("Expected refs/heads directory to be created")
+		}
+
+		// Check if format file was created
+		if format := utils.ReadFormat(); format != utils.CurrentFormat() {
+			t.Errorf("Expected repository format %v, got %v", utils.CurrentFormat(), format)
 		}
 
 		// Check file permissions
@@ -82,7 +92,9 @@ func TestInitCommand(t *testing.T) {
 			"objects": true,
 			"index":   true,
 			"HEAD":    true,
-			"LATEST":  true,
+			"refs":    true,
+			"format":  true,
+			"info":    true,
 		}
 
 		for _, entry := range entries {
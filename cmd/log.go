@@ -4,62 +4,275 @@ import (
 	"encoding/json"
 	"fmt"
 	"microgit/utils"
-	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
-func readCommit(hash string) (utils.SavePoint, error) {
-	objectPath := filepath.Join(utils.DEFAULT_PATH, "objects", hash)
+var (
+	logAuthor string
+	logFormat string
+	logFollow string
+)
 
-	data, err := os.ReadFile(objectPath)
+func readCommit(hash string) (utils.SavePoint, error) {
+	kind, data, err := utils.ReadObject(hash)
 	if err != nil {
 		return utils.SavePoint{}, fmt.Errorf("could not read commit object: %w", err)
 	}
+	if kind != utils.SAVEPOINT_OBJECT {
+		return utils.SavePoint{}, fmt.Errorf("object %s is a %s, not a save point", hash, kind)
+	}
 
 	var commit utils.SavePoint
 	err = json.Unmarshal(data, &commit)
 	if err != nil {
 		return utils.SavePoint{}, fmt.Errorf("failed to parse commit JSON: %w", err)
 	}
+
+	// Older save points have a single Parent; newer ones list Parents
+	commit.Parents = commit.ParentHashes()
+	if len(commit.Parents) > 0 {
+		commit.Parent = commit.Parents[0]
+	}
+
+	// Older save points store Files inline; newer ones point at a root tree
+	if commit.Tree != "" {
+		files, err := utils.FlattenTree(commit.Tree)
+		if err != nil {
+			return utils.SavePoint{}, err
+		}
+		commit.Files = files
+	}
 	return commit, nil
 }
 
+// historyOrder returns every save point reachable from the given ones,
+// newest first, never listing a save point before any of its children
+func historyOrder(hashes ...string) []string {
+	commits := make(map[string]utils.SavePoint)
+	children := make(map[string]int)
+	for hash := range ancestors(nil, hashes...) {
+		commit, err := readCommit(hash)
+		if err != nil {
+			continue
+		}
+		commits[hash] = commit
+		for _, parent := range commit.Parents {
+			children[parent]++
+		}
+	}
+
+	var ready []string
+	for hash := range commits {
+		if children[hash] == 0 {
+			ready = append(ready, hash)
+		}
+	}
+
+	var order []string
+	for len(ready) > 0 {
+		// Take the newest save point whose children have all been listed
+		newest := 0
+		for i, hash := range ready {
+			current, best := commits[hash], commits[ready[newest]]
+			if current.Timestamp > best.Timestamp || (current.Timestamp == best.Timestamp && hash < ready[newest]) {
+				newest = i
+			}
+		}
+		hash := ready[newest]
+		ready = append(ready[:newest], ready[newest+1:]...)
+		order = append(order, hash)
+
+		for _, parent := range commits[hash].Parents {
+			children[parent]--
+			if _, ok := commits[parent]; ok && children[parent] == 0 {
+				ready = append(ready, parent)
+			}
+		}
+	}
+	return order
+}
+
+// followPath picks the save points of a newest-first history that changed
+// path, following the file back through renames: once the save point that
+// renamed it is reached, older save points are searched for its old name
+func followPath(order []string, path string) (map[string]bool, error) {
+	path = filepath.ToSlash(filepath.Clean(path))
+	touched := make(map[string]bool)
+
+	for _, hash := range order {
+		commit, err := readCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		parent := utils.SavePoint{}
+		if commit.Parent != "" {
+			if parent, err = readCommit(commit.Parent); err != nil {
+				return nil, err
+			}
+		}
+
+		if commit.Files[path] == parent.Files[path] {
+			continue
+		}
+		touched[hash] = true
+
+		if _, existed := parent.Files[path]; existed || commit.Files[path] == "" {
+			continue
+		}
+		renames, err := detectRenames(diffSide{files: parent.Files}, diffSide{files: commit.Files})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range renames {
+			if r.path == path {
+				path = r.oldPath
+				break
+			}
+		}
+	}
+	return touched, nil
+}
+
+// tagDecoration lists tags the way log shows them after a hash
+func tagDecoration(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return " (tag: " + strings.Join(tags, ", tag: ") + ")"
+}
+
+// modifiedFiles returns the sorted paths a save point changed from its first
+// parent; for the first save point that is every file it has
+func modifiedFiles(commit utils.SavePoint) ([]string, error) {
+	parent := utils.SavePoint{}
+	if commit.Parent != "" {
+		var err error
+		if parent, err = readCommit(commit.Parent); err != nil {
+			return nil, err
+		}
+	}
+	return changedPaths(diffSide{files: parent.Files}, diffSide{files: commit.Files}), nil
+}
+
+// printSavePoint prints one log entry, decorated with the save point's tags
+func printSavePoint(hash string, commit utils.SavePoint, tags []string) {
+	fmt.Printf("Commit: %s%s\n", hash, tagDecoration(tags))
+	if len(commit.Parents) > 1 {
+		fmt.Printf("Merge: %s\n", strings.Join(commit.Parents, " "))
+	}
+	if author := commit.Author(); author != "" {
+		fmt.Printf("Author: %s\n", author)
+	}
+	if committer := commit.Committer(); committer != "" && committer != commit.Author() {
+		fmt.Printf("Committer: %s\n", committer)
+	}
+	fmt.Printf("Date: %s\n", commit.Timestamp)
+	fmt.Printf("Message: %s\n", commit.Message)
+	fmt.Print("Files modified: ")
+	files, err := modifiedFiles(commit)
+	if err != nil {
+		fmt.Printf("(could not read parent: %v)", err)
+	}
+	for _, file := range files {
+		fmt.Printf("%s ", file)
+	}
+
+	fmt.Print("\n\n")
+}
+
+// printOneline prints a save point as its abbreviated hash, tags and the
+// first line of its message
+func printOneline(hash string, commit utils.SavePoint, tags []string) {
+	summary, _, _ := strings.Cut(commit.Message, "\n")
+	fmt.Printf("%s%s %s\n", hash[:12], tagDecoration(tags), summary)
+}
+
 // logCmd represents the log command
 var logCmd = &cobra.Command{
-	Use:   "log",
+	Use:   "log [<rev>]",
 	Short: "Show the commit history",
 	Long: `Display the commit history in chronological order, starting from the most recent commit.
 For each commit, it shows:
 - The commit hash
 - The timestamp
 - The commit message
-- The list of files that were modified`,
+- The author, and the committer when someone else made the save point
+- The list of files that were modified
+
+History starts from HEAD, or from <rev> when one is given. --author limits
+it to save points whose "Name <email>" author matches a regular expression.
+--follow <path> limits it to save points that changed the file, following it
+back through renames.
+
+--format oneline prints one line per save point instead; the log.format
+setting changes the default.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		head := getHead()
+		if len(args) > 0 {
+			hash, err := resolveRevision(args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			head = hash
+		}
 		if head == "" {
 			fmt.Println("No commits yet.")
+			return
 		}
 
-		current := head
-		for current != "" {
-			commit, err := readCommit(current)
+		format := logFormat
+		if format == "" {
+			format = configString("log.format", "full")
+		}
+		show := printSavePoint
+		switch format {
+		case "full":
+		case "oneline":
+			show = printOneline
+		default:
+			fmt.Printf("Error: unknown log format %q; use full or oneline\n", format)
+			return
+		}
+
+		var author *regexp.Regexp
+		if logAuthor != "" {
+			pattern, err := regexp.Compile(logAuthor)
 			if err != nil {
-				fmt.Println("Error reading commit:", err)
+				fmt.Printf("Error: invalid --author pattern: %v\n", err)
+				return
 			}
+			author = pattern
+		}
 
-			fmt.Printf("Commit: %s\n", current)
-			fmt.Printf("Date: %s\n", commit.Timestamp)
-			fmt.Printf("Message: %s\n", commit.Message)
-			fmt.Print("Files modified: ")
-			for key := range commit.Files {
-				fmt.Printf("%s ", key)
+		order := historyOrder(head)
+		var touched map[string]bool
+		if logFollow != "" {
+			var err error
+			if touched, err = followPath(order, logFollow); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
 			}
+		}
 
-			fmt.Print("\n\n")
-
-			current = commit.Parent
+		tags := tagsBySavePoint()
+		for _, current := range order {
+			if touched != nil && !touched[current] {
+				continue
+			}
+			commit, err := readCommit(current)
+			if err != nil {
+				fmt.Println("Error reading commit:", err)
+				continue
+			}
+			if author != nil && !author.MatchString(commit.Author()) {
+				continue
+			}
+			show(current, commit, tags[current])
 		}
 	},
 }
@@ -67,13 +280,7 @@ For each commit, it shows:
 func init() {
 	rootCmd.AddCommand(logCmd)
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// logCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// logCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	logCmd.Flags().StringVar(&logFormat, "format", "", "Output format: full or oneline (default from log.format, else full)")
+	logCmd.Flags().StringVar(&logAuthor, "author", "", "Only show save points whose author matches the pattern")
+	logCmd.Flags().StringVar(&logFollow, "follow", "", "Only show save points that changed the file, following renames")
 }
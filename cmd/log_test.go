@@ -37,12 +37,7 @@ func TestReadCommit(t *testing.T) {
 	addCmd.Run(nil, []string{"temp.txt"})
 	saveCmd.Run(nil, []string{"temp"})
 
-	headPath := filepath.Join(utils.DEFAULT_PATH, "HEAD")
-	data, err := os.ReadFile(headPath)
-	if err != nil {
-		t.Errorf("Error reading HEAD file %v", err)
-	}
-	commitHash := strings.TrimSpace(string(data))
+	commitHash := getHead()
 
 	t.Run("successful commit read", func(t *testing.T) {
 		// Test reading the commit
@@ -63,6 +58,25 @@ func TestReadCommit(t *testing.T) {
 		}
 	})
 
+	t.Run("modified files are compared with the parent", func(t *testing.T) {
+		os.WriteFile("b.txt", []byte("b"), 0644)
+		os.WriteFile("a.txt", []byte("a"), 0644)
+		addCmd.Run(nil, []string{"b.txt", "a.txt"})
+		saveCmd.Run(nil, []string{"more"})
+
+		commit, err := readCommit(getHead())
+		if err != nil {
+			t.Fatalf("readCommit failed: %v", err)
+		}
+		files, err := modifiedFiles(commit)
+		if err != nil {
+			t.Fatalf("modifiedFiles failed: %v", err)
+		}
+		if strings.Join(files, ",") != "a.txt,b.txt" {
+			t.Errorf("Expected only the new files in order, got %v", files)
+		}
+	})
+
 	t.Run("nonexistent commit", func(t *testing.T) {
 		_, err := readCommit("nonexistent")
 		if err == nil {
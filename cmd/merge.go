@@ -0,0 +1,448 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"microgit/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var mergeAbort bool
+
+// mergeResult is the outcome of merging two snapshots
+type mergeResult struct {
+	// files holds every cleanly merged path -> blob hash
+	files map[string]string
+	// conflicted holds the working tree content of every conflicted path
+	conflicted map[string][]byte
+}
+
+func mergeHeadPath() string {
+	return filepath.Join(utils.DEFAULT_PATH, "MERGE_HEAD")
+}
+
+// readMergeHead returns the save point being merged, or "" if no merge is in progress
+func readMergeHead() string {
+	data, err := os.ReadFile(mergeHeadPath())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func mergeConflictsPath() string {
+	return filepath.Join(utils.DEFAULT_PATH, "MERGE_CONFLICTS")
+}
+
+// readMergeConflicts returns the conflicted paths of the merge in progress
+// that have not been added again since
+func readMergeConflicts() []string {
+	data, err := os.ReadFile(mergeConflictsPath())
+	if err != nil {
+		return nil
+	}
+	var conflicts []string
+	json.Unmarshal(data, &conflicts)
+	return conflicts
+}
+
+// writeMergeConflicts records the paths that must be resolved before the
+// merge can be saved
+func writeMergeConflicts(conflicts []string) error {
+	if len(conflicts) == 0 {
+		if err := os.Remove(mergeConflictsPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(conflicts)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(mergeConflictsPath(), data, 0644)
+}
+
+// resolveConflicts marks paths as resolved once they are staged or removed
+func resolveConflicts(paths ...string) error {
+	conflicts := readMergeConflicts()
+	if len(conflicts) == 0 {
+		return nil
+	}
+	resolved := make(map[string]bool)
+	for _, path := range paths {
+		resolved[path] = true
+	}
+
+	var remaining []string
+	for _, path := range conflicts {
+		if !resolved[path] {
+			remaining = append(remaining, path)
+		}
+	}
+	if len(remaining) == len(conflicts) {
+		return nil
+	}
+	return writeMergeConflicts(remaining)
+}
+
+func clearMergeState() {
+	os.Remove(mergeHeadPath())
+	os.Remove(mergeConflictsPath())
+}
+
+// mergeBase returns the best common ancestor of two save points: the newest
+// common ancestor that is not itself an ancestor of another common one
+func mergeBase(ours, theirs string) string {
+	ourAncestors := ancestors(nil, ours)
+
+	candidates := make(map[string]bool)
+	for hash := range ancestors(nil, theirs) {
+		if ourAncestors[hash] {
+			candidates[hash] = true
+		}
+	}
+
+	for hash := range candidates {
+		commit, err := readCommit(hash)
+		if err != nil {
+			continue
+		}
+		for older := range ancestors(nil, commit.Parents...) {
+			delete(candidates, older)
+		}
+	}
+
+	best := ""
+	bestTimestamp := ""
+	for _, hash := range sortedKeys(candidates) {
+		commit, _ := readCommit(hash)
+		if best == "" || commit.Timestamp > bestTimestamp {
+			best, bestTimestamp = hash, commit.Timestamp
+		}
+	}
+	return best
+}
+
+// readBlob returns the content of a blob, or nil for an empty hash
+func readBlob(hash string) ([]byte, error) {
+	if hash == "" {
+		return nil, nil
+	}
+
+	kind, content, err := utils.ReadObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if kind != utils.BLOB_OBJECT {
+		return nil, fmt.Errorf("object %s is a %s, not a blob", hash, kind)
+	}
+	return content, nil
+}
+
+// mergeSnapshots merges the path -> blob maps of ours and theirs against
+// their common base, file by file
+func mergeSnapshots(base, ours, theirs map[string]string, labels utils.MergeLabels) (mergeResult, error) {
+	result := mergeResult{files: map[string]string{}, conflicted: map[string][]byte{}}
+
+	paths := make(map[string]bool)
+	for _, files := range []map[string]string{base, ours, theirs} {
+		for path := range files {
+			paths[path] = true
+		}
+	}
+
+	for path := range paths {
+		b, o, t := base[path], ours[path], theirs[path]
+
+		switch {
+		case o == t:
+			if o != "" {
+				result.files[path] = o
+			}
+			continue
+		case o == b:
+			if t != "" {
+				result.files[path] = t
+			}
+			continue
+		case t == b:
+			if o != "" {
+				result.files[path] = o
+			}
+			continue
+		}
+
+		// Both sides changed the file in different ways
+		ourContent, err := readBlob(o)
+		if err != nil {
+			return result, err
+		}
+		theirContent, err := readBlob(t)
+		if err != nil {
+			return result, err
+		}
+
+		// One side deleted what the other modified; keep the modified file
+		if o == "" {
+			result.conflicted[path] = theirContent
+			continue
+		}
+		if t == "" {
+			result.conflicted[path] = ourContent
+			continue
+		}
+
+		baseContent, err := readBlob(b)
+		if err != nil {
+			return result, err
+		}
+		if utils.IsBinary(baseContent) || utils.IsBinary(ourContent) || utils.IsBinary(theirContent) {
+			result.conflicted[path] = ourContent
+			continue
+		}
+
+		merged, conflict := utils.Merge3(baseContent, ourContent, theirContent, labels)
+		if conflict {
+			result.conflicted[path] = merged
+			continue
+		}
+
+		hash, err := utils.WriteObject(utils.BLOB_OBJECT, merged)
+		if err != nil {
+			return result, err
+		}
+		result.files[path] = hash
+	}
+
+	return result, nil
+}
+
+// stagedFiles returns the index for a merge that stopped on conflicts:
+// every cleanly merged file, with conflicted files left as they are in ours
+func (result mergeResult) stagedFiles(ours map[string]string) map[string]string {
+	staged := make(map[string]string)
+	for path, hash := range result.files {
+		staged[path] = hash
+	}
+	for path := range result.conflicted {
+		if hash, ok := ours[path]; ok {
+			staged[path] = hash
+		}
+	}
+	return staged
+}
+
+// applyMergeResult writes the merge outcome over the working tree that
+// currently matches ours
+func applyMergeResult(ours map[string]string, result mergeResult) error {
+	if err := updateWorkingTree(ours, result.files); err != nil {
+		return err
+	}
+
+	for path, content := range result.conflicted {
+		if dir := filepath.Dir(path); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func runMerge(rev string) error {
+	if readMergeHead() != "" {
+		return fmt.Errorf("a merge is already in progress; save it or run microgit merge --abort")
+	}
+
+	head := getHead()
+	if head == "" {
+		return fmt.Errorf("nothing has been saved yet")
+	}
+	theirHash, err := resolveRevision(rev)
+	if err != nil {
+		return err
+	}
+
+	if ancestors(nil, head)[theirHash] {
+		fmt.Println("Already up to date.")
+		return nil
+	}
+
+	ours, err := readCommit(head)
+	if err != nil {
+		return err
+	}
+	theirs, err := readCommit(theirHash)
+	if err != nil {
+		return err
+	}
+	if err := checkClean(theirs.Files, ours.Files); err != nil {
+		return err
+	}
+
+	// Nothing to merge if our side has no save points of its own
+	if ancestors(nil, theirHash)[head] {
+		if err := updateWorkingTree(ours.Files, theirs.Files); err != nil {
+			return err
+		}
+		if err := writeIndex(theirs.Files); err != nil {
+			return err
+		}
+		if err := setHead(theirHash); err != nil {
+			return err
+		}
+		fmt.Printf("Fast-forward to %s\n", theirHash)
+		return nil
+	}
+
+	baseFiles := map[string]string{}
+	if baseHash := mergeBase(head, theirHash); baseHash != "" {
+		base, err := readCommit(baseHash)
+		if err != nil {
+			return err
+		}
+		baseFiles = base.Files
+	}
+
+	result, err := mergeSnapshots(baseFiles, ours.Files, theirs.Files, utils.MergeLabels{Ours: "HEAD", Theirs: rev})
+	if err != nil {
+		return err
+	}
+	if err := applyMergeResult(ours.Files, result); err != nil {
+		return err
+	}
+
+	if len(result.conflicted) > 0 {
+		// Stage everything that merged cleanly and wait for the user to
+		// resolve the rest
+		if err := writeIndex(result.stagedFiles(ours.Files)); err != nil {
+			return err
+		}
+		if err := os.WriteFile(mergeHeadPath(), []byte(theirHash), 0644); err != nil {
+			return err
+		}
+		conflicts := sortedKeys(result.conflicted)
+		if err := writeMergeConflicts(conflicts); err != nil {
+			return err
+		}
+
+		for _, path := range conflicts {
+			fmt.Printf("CONFLICT: merge conflict in %s\n", path)
+		}
+		fmt.Println("Automatic merge failed; fix conflicts, add the files and run microgit save")
+		return nil
+	}
+
+	target := currentBranch()
+	if target == "" {
+		target = "HEAD"
+	}
+	hash, err := writeSavePointObject(utils.SavePoint{
+		Message:   fmt.Sprintf("Merge %s into %s", rev, target),
+		Timestamp: time.Now().Format(time.RFC3339),
+		Parents:   []string{head, theirHash},
+		Files:     result.files,
+	})
+	if err != nil {
+		return err
+	}
+	if err := setHead(hash); err != nil {
+		return err
+	}
+	if err := writeIndex(result.files); err != nil {
+		return err
+	}
+
+	fmt.Printf("Merged %s: %s\n", rev, hash)
+	return nil
+}
+
+func abortMerge() error {
+	mergeHead := readMergeHead()
+	if mergeHead == "" {
+		return fmt.Errorf("no merge in progress")
+	}
+
+	ours := getCommittedFiles()
+	theirs, err := readCommit(mergeHead)
+	if err != nil {
+		return err
+	}
+
+	// Everything the merge may have written is reset to HEAD
+	touched := make(map[string]string)
+	for path, hash := range theirs.Files {
+		touched[path] = hash
+	}
+	for path, hash := range ours {
+		touched[path] = hash
+	}
+	if err := updateWorkingTree(touched, ours); err != nil {
+		return err
+	}
+
+	if err := writeIndex(ours); err != nil {
+		return err
+	}
+	clearMergeState()
+	return nil
+}
+
+// mergeCmd represents the merge command
+var mergeCmd = &cobra.Command{
+	Use:   "merge <rev>",
+	Short: "Join another line of history into the current one",
+	Long: `Merge the changes made on another branch or save point into HEAD.
+
+Usage:
+  microgit merge <rev>      - Merge a branch or save point into HEAD
+  microgit merge --abort    - Give up on a merge that stopped on conflicts
+
+The merge finds the most recent common ancestor of HEAD and <rev>, then
+merges every file three ways. Changes made on only one side are taken as
+they are. When both sides changed the same lines differently, the file is
+written with conflict markers:
+
+  <<<<<<< HEAD
+  our lines
+  =======
+  their lines
+  >>>>>>> <rev>
+
+If everything merges cleanly a save point with two parents is created.
+Otherwise fix the conflicted files, add them and run microgit save; save
+refuses to run while a conflicted file has not been added or removed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if mergeAbort {
+			if err := abortMerge(); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			fmt.Println("Merge aborted")
+			return
+		}
+
+		if len(args) == 0 {
+			fmt.Println("Error: No revision specified")
+			return
+		}
+
+		if err := runMerge(args[0]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mergeCmd)
+
+	mergeCmd.Flags().BoolVar(&mergeAbort, "abort", false, "Abort a merge that stopped on conflicts")
+}
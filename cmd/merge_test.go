@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"encoding/json"
+	"microgit/utils"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMergeCmd(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	save := func(content, message string) string {
+		if err := os.WriteFile("f.txt", []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile failed %v", err)
+		}
+		addCmd.Run(nil, []string{"f.txt"})
+		saveCmd.Run(nil, []string{message})
+		return getHead()
+	}
+
+	base := save("1\n2\n3\n4\n5\n", "base")
+	branchCmd.Run(nil, []string{"feature"})
+	checkoutCmd.Run(nil, []string{"feature"})
+	theirs := save("A\n2\n3\n4\n5\n", "change the first line")
+	checkoutCmd.Run(nil, []string{DEFAULT_BRANCH})
+	ours := save("1\n2\n3\n4\nB\n", "change the last line")
+
+	t.Run("merge base", func(t *testing.T) {
+		if got := mergeBase(ours, theirs); got != base {
+			t.Errorf("mergeBase = %s, want %s", got, base)
+		}
+	})
+
+	t.Run("clean merge creates a save point with two parents", func(t *testing.T) {
+		mergeCmd.Run(nil, []string{"feature"})
+
+		commit, err := readCommit(getHead())
+		if err != nil {
+			t.Fatalf("readCommit failed: %v", err)
+		}
+		if len(commit.Parents) != 2 || commit.Parents[0] != ours || commit.Parents[1] != theirs {
+			t.Errorf("Expected parents [%s %s], got %v", ours, theirs, commit.Parents)
+		}
+
+		content, _ := os.ReadFile("f.txt")
+		if string(content) != "A\n2\n3\n4\nB\n" {
+			t.Errorf("Unexpected merged content %q", content)
+		}
+	})
+
+	t.Run("already merged", func(t *testing.T) {
+		head := getHead()
+		mergeCmd.Run(nil, []string{"feature"})
+		if getHead() != head {
+			t.Errorf("Expected merging an ancestor to do nothing")
+		}
+	})
+
+	t.Run("conflicting changes stop with markers", func(t *testing.T) {
+		checkoutCmd.Run(nil, []string{"feature"})
+		save("A\n2\nfeature\n4\n5\n", "feature edits line three")
+		checkoutCmd.Run(nil, []string{DEFAULT_BRANCH})
+		head := save("A\n2\nmain\n4\nB\n", "main edits line three")
+
+		mergeCmd.Run(nil, []string{"feature"})
+
+		if getHead() != head {
+			t.Errorf("Expected no save point to be created on conflict")
+		}
+		if readMergeHead() != readRef(branchRef("feature")) {
+			t.Errorf("Expected MERGE_HEAD to record the other side")
+		}
+
+		content, _ := os.ReadFile("f.txt")
+		want := "A\n2\n<<<<<<< HEAD\nmain\n=======\nfeature\n>>>>>>> feature\n4\nB\n"
+		if string(content) != want {
+			t.Errorf("Unexpected conflict content %q, want %q", content, want)
+		}
+
+		// Saving before the conflict is added again would record ours
+		saveCmd.Run(nil, []string{"too early"})
+		if getHead() != head || readMergeHead() == "" {
+			t.Errorf("Expected save to refuse while f.txt is unresolved")
+		}
+		if conflicts := readMergeConflicts(); len(conflicts) != 1 || conflicts[0] != "f.txt" {
+			t.Errorf("Expected f.txt to be recorded as conflicted, got %v", conflicts)
+		}
+
+		// Resolve and conclude the merge
+		save("A\n2\nboth\n4\nB\n", "merge feature")
+		commit, err := readCommit(getHead())
+		if err != nil {
+			t.Fatalf("readCommit failed: %v", err)
+		}
+		if len(commit.Parents) != 2 {
+			t.Errorf("Expected the resolving save to have two parents, got %v", commit.Parents)
+		}
+		if readMergeHead() != "" || readMergeConflicts() != nil {
+			t.Errorf("Expected merge state to be cleared after saving")
+		}
+	})
+
+	t.Run("single parent save points still parse", func(t *testing.T) {
+		data, _ := json.Marshal(map[string]interface{}{
+			"message":   "old style",
+			"timestamp": "2020-01-01T00:00:00Z",
+			"parent":    base,
+			"files":     map[string]string{},
+		})
+		hash, err := utils.WriteObject(utils.SAVEPOINT_OBJECT, data)
+		if err != nil {
+			t.Fatalf("WriteObject failed: %v", err)
+		}
+
+		commit, err := readCommit(hash)
+		if err != nil {
+			t.Fatalf("readCommit failed: %v", err)
+		}
+		if strings.Join(commit.Parents, ",") != base || commit.Parent != base {
+			t.Errorf("Expected the single parent to be read as Parents, got %v", commit.Parents)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"fmt"
+	"microgit/utils"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// upgradeHistory re-encodes the objects written before type headers existed.
+// Their hashes change, so every save point that refers to one, directly or
+// through a parent, is rewritten, and refs and the index are moved to the
+// new hashes. It returns old -> new hashes for every rewritten save point.
+func upgradeHistory() (map[string]string, error) {
+	blobs := make(map[string]string)
+	upgradeBlob := func(hash string) (string, error) {
+		if upgraded, ok := blobs[hash]; ok {
+			return upgraded, nil
+		}
+		upgraded, err := utils.UpgradeObject(hash)
+		if err != nil {
+			return "", err
+		}
+		blobs[hash] = upgraded
+		return upgraded, nil
+	}
+
+	order := historyOrder(historyRoots()...)
+	rewritten := make(map[string]string)
+
+	// Parents are rewritten before their children
+	for i := len(order) - 1; i >= 0; i-- {
+		hash := order[i]
+		commit, err := readCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		// A save point without a header is rewritten even if nothing it
+		// refers to changes
+		changed, err := utils.IsLegacyObject(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		parents := make([]string, len(commit.Parents))
+		for j, parent := range commit.Parents {
+			parents[j] = parent
+			if replacement, ok := rewritten[parent]; ok {
+				parents[j] = replacement
+				changed = true
+			}
+		}
+
+		files := make(map[string]string, len(commit.Files))
+		for path, blob := range commit.Files {
+			if files[path], err = upgradeBlob(blob); err != nil {
+				return nil, err
+			}
+			if files[path] != blob {
+				changed = true
+			}
+		}
+
+		if !changed {
+			continue
+		}
+
+		// Everything but the references stays as it was, including who
+		// made the save point and when
+		commit.Files = files
+		commit.Parents = parents
+		commit.Parent = ""
+		commit.Tree = ""
+		replacement, err := storeSavePoint(commit)
+		if err != nil {
+			return nil, err
+		}
+		rewritten[hash] = replacement
+	}
+
+	if err := rewriteRefs(rewritten); err != nil {
+		return rewritten, err
+	}
+
+	index, err := readIndex()
+	if err != nil && !os.IsNotExist(err) {
+		return rewritten, err
+	}
+	for path, blob := range index {
+		if index[path], err = upgradeBlob(blob); err != nil {
+			return rewritten, err
+		}
+	}
+	return rewritten, writeIndex(index)
+}
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the repository to the current storage format",
+	Long: `Upgrade an existing repository to the current storage format.
+
+Repositories created by older versions of MicroGit store objects
+uncompressed. This command compresses every loose object in place and
+records the new format, so new objects are compressed as well.
+Objects still kept in a single flat directory are moved into fan-out
+directories first, and an older text index is rewritten in the binary format.
+
+Objects written before type headers existed are stored again with a header,
+which gives them new hashes. Save points referring to them are rewritten
+with their messages, dates and identities kept, and branches, tags, HEAD and
+the index are moved to the new hashes. The old objects stay until
+microgit gc removes them.
+
+The command exits with a non-zero status if the upgrade fails.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := os.Stat(utils.DEFAULT_PATH); os.IsNotExist(err) {
+			fmt.Println("Not a MicroGit repository")
+			return
+		}
+
+		if _, err := utils.ShardObjects(); err != nil {
+			fmt.Printf("Error moving objects into fan-out directories: %v\n", err)
+			os.Exit(1)
+		}
+		if err := migrateRefs(); err != nil {
+			fmt.Printf("Error upgrading references: %v\n", err)
+			os.Exit(1)
+		}
+		if err := upgradeIndex(); err != nil {
+			fmt.Printf("Error upgrading the index: %v\n", err)
+			os.Exit(1)
+		}
+
+		rewritten, err := upgradeHistory()
+		if err != nil {
+			fmt.Printf("Error upgrading save points: %v\n", err)
+			os.Exit(1)
+		}
+		for _, old := range sortedKeys(rewritten) {
+			fmt.Printf("%s -> %s\n", old, rewritten[old])
+		}
+		if len(rewritten) > 0 {
+			fmt.Printf("Rewrote %d save point(s) with typed objects\n", len(rewritten))
+		}
+
+		hashes, err := utils.ListLooseObjects()
+		if err != nil {
+			fmt.Printf("Error reading objects: %v\n", err)
+			os.Exit(1)
+		}
+
+		converted := 0
+		for _, hash := range hashes {
+			rewritten, err := utils.CompressLooseObject(hash)
+			if err != nil {
+				fmt.Printf("Error migrating object %s: %v\n", hash, err)
+				os.Exit(1)
+			}
+			if rewritten {
+				converted++
+			}
+		}
+
+		if err := utils.WriteFormat(utils.CurrentFormat()); err != nil {
+			fmt.Printf("Error writing repository format: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Migrated %d object(s) to compressed storage\n", converted)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+}
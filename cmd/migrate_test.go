@@ -0,0 +1,357 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"microgit/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrateCmd(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	// Simulate a repository created before compression existed
+	if err := os.Remove(filepath.Join(utils.DEFAULT_PATH, "format")); err != nil {
+		t.Fatalf("Failed to remove format file: %v", err)
+	}
+
+	content := []byte("some text that is stored as a blob")
+	hash, err := utils.WriteObject(utils.BLOB_OBJECT, content)
+	if err != nil {
+		t.Fatalf("WriteObject failed: %v", err)
+	}
+
+	objectPath := utils.ObjectPath(hash)
+	raw, err := os.ReadFile(objectPath)
+	if err != nil {
+		t.Fatalf("Failed to read object: %v", err)
+	}
+	if !strings.HasPrefix(string(raw), "blob ") {
+		t.Fatalf("Expected an uncompressed object before migrating")
+	}
+
+	migrateCmd.Run(nil, nil)
+
+	raw, err = os.ReadFile(objectPath)
+	if err != nil {
+		t.Fatalf("Failed to read object: %v", err)
+	}
+	if strings.HasPrefix(string(raw), "blob ") {
+		t.Errorf("Expected object to be compressed after migrating")
+	}
+
+	kind, got, err := utils.ReadObject(hash)
+	if err != nil {
+		t.Fatalf("ReadObject failed after migrating: %v", err)
+	}
+	if kind != utils.BLOB_OBJECT || string(got) != string(content) {
+		t.Errorf("ReadObject = %s %q, want %s %q", kind, got, utils.BLOB_OBJECT, content)
+	}
+
+	if format := utils.ReadFormat(); format != utils.CurrentFormat() {
+		t.Errorf("Expected repository format %v, got %v", utils.CurrentFormat(), format)
+	}
+}
+
+func TestUpgradeShardsFlatObjects(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	// Simulate a repository that keeps every object in objects/ directly
+	if err := os.Remove(filepath.Join(utils.DEFAULT_PATH, "format")); err != nil {
+		t.Fatalf("Failed to remove format file: %v", err)
+	}
+	object := []byte("blob 5\x00hello")
+	hash := utils.HashContent(object)
+	flatPath := filepath.Join(utils.DEFAULT_PATH, "objects", hash)
+	if err := os.WriteFile(flatPath, object, 0644); err != nil {
+		t.Fatalf("Failed to write flat object: %v", err)
+	}
+
+	upgradeRepository()
+
+	if _, err := os.Stat(flatPath); !os.IsNotExist(err) {
+		t.Errorf("Expected flat object to be moved")
+	}
+	if _, err := os.Stat(utils.ObjectPath(hash)); err != nil {
+		t.Errorf("Expected object at %s: %v", utils.ObjectPath(hash), err)
+	}
+	if kind, content, err := utils.ReadObject(hash); err != nil || kind != utils.BLOB_OBJECT || string(content) != "hello" {
+		t.Errorf("ReadObject = %s %q %v, want blob \"hello\"", kind, content, err)
+	}
+	if layout := utils.ReadFormat().Layout; layout != utils.LAYOUT_SHARDED {
+		t.Errorf("Expected sharded layout, got %s", layout)
+	}
+}
+
+// writeBaselineRepository creates a repository the way the first versions
+// of MicroGit stored it: bare objects without type headers in a flat
+// objects/ directory, save points as plain JSON listing only the files
+// staged for them, a text index and HEAD and LATEST files. It returns the
+// save point hashes, oldest first.
+func writeBaselineRepository(t *testing.T, saves ...map[string]string) []string {
+	t.Helper()
+
+	objectsDir := filepath.Join(utils.DEFAULT_PATH, "objects")
+	if err := os.MkdirAll(objectsDir, 0755); err != nil {
+		t.Fatalf("Failed to create objects directory: %v", err)
+	}
+	writeObject := func(content []byte) string {
+		hash := utils.HashContent(content)
+		if err := os.WriteFile(filepath.Join(objectsDir, hash), content, 0644); err != nil {
+			t.Fatalf("Failed to write object: %v", err)
+		}
+		return hash
+	}
+
+	var hashes []string
+	parent := ""
+	for i, files := range saves {
+		staged := make(map[string]string)
+		for path, content := range files {
+			staged[path] = writeObject([]byte(content))
+			os.MkdirAll(filepath.Dir(path), 0755)
+			os.WriteFile(path, []byte(content), 0644)
+		}
+
+		savePoint := struct {
+			Message   string            `json:"message"`
+			Timestamp string            `json:"timestamp"`
+			Parent    string            `json:"parent"`
+			Files     map[string]string `json:"files"`
+		}{fmt.Sprintf("save %d", i+1), fmt.Sprintf("2024-01-0%dT10:00:00Z", i+1), parent, staged}
+		data, err := json.MarshalIndent(savePoint, "", "  ")
+		if err != nil {
+			t.Fatalf("Failed to encode save point: %v", err)
+		}
+		parent = writeObject(data)
+		hashes = append(hashes, parent)
+	}
+
+	os.WriteFile(filepath.Join(utils.DEFAULT_PATH, "index"), []byte(""), 0644)
+	os.WriteFile(filepath.Join(utils.DEFAULT_PATH, "HEAD"), []byte(parent), 0644)
+	os.WriteFile(filepath.Join(utils.DEFAULT_PATH, "LATEST"), []byte(parent), 0644)
+	return hashes
+}
+
+func TestReadBaselineRepository(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	saves := writeBaselineRepository(t,
+		map[string]string{"a.txt": "first file\n", "data.json": "{\"timestamp\": 1}\n", "a.py": "x = 1\n"},
+		map[string]string{"b.txt": "second file\n"},
+	)
+
+	upgradeRepository()
+
+	t.Run("save points and blobs are read", func(t *testing.T) {
+		order := historyOrder(getHead())
+		if strings.Join(order, " ") != saves[1]+" "+saves[0] {
+			t.Fatalf("Expected history %v, got %v", []string{saves[1], saves[0]}, order)
+		}
+
+		commit, err := readCommit(saves[0])
+		if err != nil {
+			t.Fatalf("readCommit failed: %v", err)
+		}
+		if commit.Message != "save 1" || len(commit.Files) != 3 {
+			t.Errorf("Unexpected save point %+v", commit)
+		}
+
+		kind, content, err := utils.ReadObject(commit.Files["data.json"])
+		if err != nil || kind != utils.BLOB_OBJECT || string(content) != "{\"timestamp\": 1}\n" {
+			t.Errorf("ReadObject = %s %q %v, want the JSON file as a blob", kind, content, err)
+		}
+
+		// Starts with bytes that look like a zlib header
+		kind, content, err = utils.ReadObject(commit.Files["a.py"])
+		if err != nil || kind != utils.BLOB_OBJECT || string(content) != "x = 1\n" {
+			t.Errorf("ReadObject = %s %q %v, want the Python file as a blob", kind, content, err)
+		}
+	})
+
+	t.Run("status sees the saved files", func(t *testing.T) {
+		index, committed, working, err := getStatusData()
+		if err != nil {
+			t.Fatalf("getStatusData failed: %v", err)
+		}
+		if committed["b.txt"] == "" || index["b.txt"] != committed["b.txt"] || working["b.txt"] != committed["b.txt"] {
+			t.Errorf("Expected b.txt to be tracked and unchanged, got index %v committed %v working %v", index, committed, working)
+		}
+
+		changes, err := uncommittedChanges()
+		if err != nil || len(changes) != 0 {
+			t.Errorf("Expected no uncommitted changes, got %v %v", changes, err)
+		}
+	})
+
+	t.Run("fsck accepts the objects", func(t *testing.T) {
+		report, err := runFsck()
+		if err != nil {
+			t.Fatalf("runFsck failed: %v", err)
+		}
+		if !report.ok() {
+			t.Errorf("Expected a healthy repository, got corrupt %v missing %v", report.corrupt, report.missing)
+		}
+	})
+
+	t.Run("tampered objects are still corrupt", func(t *testing.T) {
+		commit, _ := readCommit(saves[1])
+		objectPath := utils.ObjectPath(commit.Files["b.txt"])
+		original, _ := os.ReadFile(objectPath)
+		defer os.WriteFile(objectPath, original, 0644)
+		os.WriteFile(objectPath, []byte("tampered\n"), 0644)
+
+		if _, _, err := utils.ReadObject(commit.Files["b.txt"]); err == nil {
+			t.Error("Expected reading a tampered object to fail")
+		}
+	})
+	t.Run("files saved before HEAD stay tracked", func(t *testing.T) {
+		index := readIndexEntries(t)
+		for _, path := range []string{"a.txt", "a.py", "data.json", "b.txt"} {
+			if index[path] == "" {
+				t.Errorf("Expected %s to be tracked, got %v", path, index)
+			}
+		}
+
+		os.WriteFile("c.txt", []byte("third file\n"), 0644)
+		addCmd.Run(nil, []string{"c.txt"})
+		saveCmd.Run(nil, []string{"save 3"})
+
+		commit, err := readCommit(getHead())
+		if err != nil {
+			t.Fatalf("readCommit failed: %v", err)
+		}
+		if len(commit.Files) != 5 || commit.Files["a.txt"] == "" {
+			t.Errorf("Expected the new save point to keep the earlier files, got %v", commit.Files)
+		}
+	})
+}
+
+func TestMigrateBaselineRepository(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	saves := writeBaselineRepository(t,
+		map[string]string{"a.txt": "first file\n", "a.py": "x = 1\n"},
+		map[string]string{"b.txt": "second file\n"},
+	)
+	upgradeRepository()
+	if err := updateRef(tagRef("v1"), saves[0]); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+
+	migrateCmd.Run(nil, nil)
+
+	head := getHead()
+	if head == saves[1] {
+		t.Fatal("Expected HEAD to move to the rewritten save point")
+	}
+	order := historyOrder(head)
+	if len(order) != 2 {
+		t.Fatalf("Expected 2 save points, got %v", order)
+	}
+	if readRef(tagRef("v1")) != order[1] {
+		t.Errorf("Expected v1 to point at the rewritten first save point %s, got %s", order[1], readRef(tagRef("v1")))
+	}
+
+	for _, hash := range order {
+		commit, err := readCommit(hash)
+		if err != nil {
+			t.Fatalf("readCommit failed: %v", err)
+		}
+		if legacy, err := utils.IsLegacyObject(hash); err != nil || legacy {
+			t.Errorf("Expected save point %s to have a header (%v)", hash, err)
+		}
+		for path, blob := range commit.Files {
+			content, _ := os.ReadFile(path)
+			if blob != utils.HashObject(utils.BLOB_OBJECT, content) {
+				t.Errorf("Expected %s in %s to be stored as a typed blob", path, commit.Message)
+			}
+		}
+	}
+	if commit, _ := readCommit(head); commit.Message != "save 2" || commit.Timestamp != "2024-01-02T10:00:00Z" {
+		t.Errorf("Expected message and date to be kept, got %+v", commit)
+	}
+
+	index := readIndexEntries(t)
+	if index["b.txt"] != utils.HashObject(utils.BLOB_OBJECT, []byte("second file\n")) {
+		t.Errorf("Expected the index to use the typed blob, got %v", index)
+	}
+
+	report, err := runFsck()
+	if err != nil {
+		t.Fatalf("runFsck failed: %v", err)
+	}
+	if !report.ok() {
+		t.Errorf("Expected a healthy repository, got corrupt %v missing %v", report.corrupt, report.missing)
+	}
+	if format := utils.ReadFormat(); format != utils.CurrentFormat() {
+		t.Errorf("Expected repository format %v, got %v", utils.CurrentFormat(), format)
+	}
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var mvForce bool
+
+// planMove works out where source ends up and which index entries move with
+// it, without touching anything
+func planMove(index map[string]string, source, destination string, intoDir, force bool) (rename, map[string]string, error) {
+	source = filepath.ToSlash(filepath.Clean(source))
+	destination = filepath.ToSlash(filepath.Clean(destination))
+	if intoDir {
+		destination = path.Join(destination, path.Base(source))
+	}
+
+	// A file moves its own entry; a directory moves every entry inside it
+	entries := make(map[string]string)
+	for file := range index {
+		if file == source {
+			entries[file] = destination
+		} else if rest, ok := strings.CutPrefix(file, source+"/"); ok {
+			entries[file] = destination + "/" + rest
+		}
+	}
+	_, isFile := index[source]
+
+	switch {
+	case len(entries) == 0:
+		return rename{}, nil, fmt.Errorf("%s is not tracked", source)
+	case destination == source || strings.HasPrefix(destination, source+"/"):
+		return rename{}, nil, fmt.Errorf("cannot move %s into itself", source)
+	}
+	if _, err := os.Lstat(source); err != nil {
+		return rename{}, nil, fmt.Errorf("%s does not exist", source)
+	}
+	if info, err := os.Stat(filepath.Dir(filepath.FromSlash(destination))); err != nil || !info.IsDir() {
+		return rename{}, nil, fmt.Errorf("destination directory %s does not exist", path.Dir(destination))
+	}
+	if info, err := os.Lstat(destination); err == nil {
+		if !force || !isFile || info.IsDir() {
+			return rename{}, nil, fmt.Errorf("%s already exists", destination)
+		}
+	}
+	return rename{oldPath: source, path: destination}, entries, nil
+}
+
+// moveFiles renames each source in the working tree and the index. Every
+// move is checked before any is made, and the files are put back if the
+// index cannot be updated, so either all of them happen or none do.
+func moveFiles(sources []string, destination string, force bool) ([]rename, error) {
+	index, err := readIndex()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	info, err := os.Stat(destination)
+	intoDir := err == nil && info.IsDir()
+	if len(sources) > 1 && !intoDir {
+		return nil, fmt.Errorf("destination %s is not a directory", destination)
+	}
+
+	var moves []rename
+	moved := make(map[string]string)
+	targets := make(map[string]bool)
+	for _, source := range sources {
+		move, entries, err := planMove(index, source, destination, intoDir, force)
+		if err != nil {
+			return nil, err
+		}
+		if targets[move.path] {
+			return nil, fmt.Errorf("more than one file would be moved to %s", move.path)
+		}
+		targets[move.path] = true
+		moves = append(moves, move)
+		for from, to := range entries {
+			moved[from] = to
+		}
+	}
+
+	undo := func(done []rename) {
+		for i := len(done) - 1; i >= 0; i-- {
+			os.Rename(filepath.FromSlash(done[i].path), filepath.FromSlash(done[i].oldPath))
+		}
+	}
+	for i, move := range moves {
+		if err := os.Rename(filepath.FromSlash(move.oldPath), filepath.FromSlash(move.path)); err != nil {
+			undo(moves[:i])
+			return nil, fmt.Errorf("failed to move %s: %w", move.oldPath, err)
+		}
+	}
+
+	updated := make(map[string]string, len(index))
+	for file, hash := range index {
+		if _, ok := moved[file]; !ok {
+			updated[file] = hash
+		}
+	}
+	for from, to := range moved {
+		updated[to] = index[from]
+	}
+	if err := writeIndex(updated); err != nil {
+		undo(moves)
+		return nil, fmt.Errorf("failed to write index: %w", err)
+	}
+	return moves, nil
+}
+
+// mvCmd represents the mv command
+var mvCmd = &cobra.Command{
+	Use:   "mv [-f] <source> <destination> | mv [-f] <sources...> <directory>",
+	Short: "Move or rename a tracked file or directory",
+	Long: `Move or rename tracked files and directories, updating the working tree
+and the index together.
+
+Usage:
+  microgit mv <source> <destination>    - Rename a file or directory
+  microgit mv <sources...> <directory>  - Move files into an existing directory
+  microgit mv -f <source> <destination> - Overwrite an existing destination file
+
+Nothing is moved unless every move can be made. The next save point records
+the files under their new names, and status, diff and log --follow show the
+change as a rename.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Usage: microgit mv <source> <destination>")
+			return
+		}
+
+		moves, err := moveFiles(args[:len(args)-1], args[len(args)-1], mvForce)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		for _, move := range moves {
+			fmt.Printf("Renamed %s -> %s\n", move.oldPath, move.path)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mvCmd)
+
+	mvCmd.Flags().BoolVarP(&mvForce, "force", "f", false, "Overwrite an existing destination file")
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMvCmd(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	defer func() { mvForce = false }()
+
+	os.MkdirAll("docs", 0755)
+	os.WriteFile("notes.txt", []byte("one\ntwo\nthree\nfour\n"), 0644)
+	os.WriteFile("other.txt", []byte("other\n"), 0644)
+	os.WriteFile(filepath.Join("docs", "guide.txt"), []byte("guide\n"), 0644)
+	addCmd.Run(nil, []string{"."})
+	saveCmd.Run(nil, []string{"first"})
+	first := getHead()
+
+	os.WriteFile("notes.txt", []byte("one\ntwo\nthree\nfour\nfive\n"), 0644)
+	addCmd.Run(nil, []string{"notes.txt"})
+	saveCmd.Run(nil, []string{"edit notes"})
+	edited := getHead()
+
+	t.Run("mv renames the file and its index entry", func(t *testing.T) {
+		mvCmd.Run(nil, []string{"notes.txt", "journal.txt"})
+
+		if _, err := os.Stat("notes.txt"); !os.IsNotExist(err) {
+			t.Error("Expected notes.txt to be moved away")
+		}
+		if content, _ := os.ReadFile("journal.txt"); string(content) != "one\ntwo\nthree\nfour\nfive\n" {
+			t.Errorf("Expected journal.txt to hold the notes, got %q", content)
+		}
+		index := readIndexEntries(t)
+		if _, ok := index["notes.txt"]; ok || index["journal.txt"] == "" {
+			t.Errorf("Expected the index entry to move, got %v", index)
+		}
+
+		renames, err := detectRenames(diffSide{files: getCommittedFiles()}, diffSide{files: index})
+		if err != nil {
+			t.Fatalf("detectRenames failed: %v", err)
+		}
+		if len(renames) != 1 || renames[0].oldPath != "notes.txt" || renames[0].path != "journal.txt" || renames[0].similarity != 100 {
+			t.Errorf("Expected the move to be detected as a rename, got %+v", renames)
+		}
+	})
+
+	t.Run("mv moves directories and files into directories", func(t *testing.T) {
+		mvCmd.Run(nil, []string{"docs", "manual"})
+		os.MkdirAll("archive", 0755)
+		mvCmd.Run(nil, []string{"other.txt", "archive"})
+
+		index := readIndexEntries(t)
+		for _, path := range []string{"manual/guide.txt", "archive/other.txt"} {
+			if index[path] == "" {
+				t.Errorf("Expected %s in the index, got %v", path, index)
+			}
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("Expected %s on disk: %v", path, err)
+			}
+		}
+	})
+
+	t.Run("mv refuses to overwrite or move untracked files", func(t *testing.T) {
+		os.WriteFile("untracked.txt", []byte("untracked\n"), 0644)
+		if _, err := moveFiles([]string{"untracked.txt"}, "elsewhere.txt", false); err == nil {
+			t.Error("Expected an error moving an untracked file")
+		}
+		if _, err := moveFiles([]string{"journal.txt", "missing.txt"}, "archive", false); err == nil {
+			t.Error("Expected an error when one of the sources is not tracked")
+		}
+		if _, err := os.Stat("journal.txt"); err != nil {
+			t.Error("Expected nothing to move when any move fails")
+		}
+		if _, err := moveFiles([]string{"journal.txt"}, "untracked.txt", false); err == nil {
+			t.Error("Expected an error overwriting an existing file")
+		}
+	})
+
+	t.Run("log --follow follows the file across the rename", func(t *testing.T) {
+		edit := []byte("one\ntwo\nthree\nfour\nfive\nsix\n")
+		os.WriteFile("journal.txt", edit, 0644)
+		addCmd.Run(nil, []string{"journal.txt"})
+		saveCmd.Run(nil, []string{"move and edit"})
+		moved := getHead()
+
+		touched, err := followPath(historyOrder(moved), "journal.txt")
+		if err != nil {
+			t.Fatalf("followPath failed: %v", err)
+		}
+		if len(touched) != 3 || !touched[moved] || !touched[edited] || !touched[first] {
+			t.Errorf("Expected every save point that changed the notes, got %v", touched)
+		}
+
+		touched, _ = followPath(historyOrder(moved), "manual/guide.txt")
+		if len(touched) != 2 || !touched[moved] || !touched[first] {
+			t.Errorf("Expected the move and the save point that added docs/guide.txt, got %v", touched)
+		}
+	})
+}
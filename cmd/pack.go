@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"microgit/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// packLooseObjects bundles every loose object into a new pack and removes
+// the loose copies
+func packLooseObjects() (utils.PackResult, error) {
+	hashes, err := utils.ListLooseObjects()
+	if err != nil {
+		return utils.PackResult{}, fmt.Errorf("failed to list objects: %w", err)
+	}
+	if len(hashes) == 0 {
+		return utils.PackResult{}, nil
+	}
+
+	result, err := utils.WritePack(hashes)
+	if err != nil {
+		return utils.PackResult{}, fmt.Errorf("failed to write pack: %w", err)
+	}
+
+	for _, hash := range hashes {
+		if err := utils.RemoveLooseObject(hash); err != nil {
+			return result, fmt.Errorf("failed to remove loose object %s: %w", hash, err)
+		}
+	}
+
+	return result, nil
+}
+
+// packCmd represents the pack command
+var packCmd = &cobra.Command{
+	Use:   "pack",
+	Short: "Bundle loose objects into a pack file",
+	Long: `Bundle every loose object into a single pack file.
+
+Objects inside a pack are stored as binary deltas against similar objects
+where that saves space, so many versions of the same file cost little more
+than one. Each pack has a sorted index used to find objects by hash.
+Packed objects are read transparently by every command.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := packLooseObjects()
+		if err != nil {
+			fmt.Printf("Error packing objects: %v\n", err)
+			return
+		}
+
+		if result.Objects == 0 {
+			fmt.Println("Nothing to pack")
+			return
+		}
+
+		fmt.Printf("Packed %d object(s) into %s (%d delta(s), %d bytes)\n", result.Objects, result.Name, result.Deltas, result.Size)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(packCmd)
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"microgit/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPackObjects(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	// Save several versions of a large file that differ by one line each
+	var lines []string
+	for i := 0; i < 500; i++ {
+		lines = append(lines, fmt.Sprintf("line number %d of a big text asset", i))
+	}
+	var versions []string
+	for v := 0; v < 5; v++ {
+		lines[v*100] = fmt.Sprintf("edited in version %d", v)
+		content := strings.Join(lines, "\n")
+		versions = append(versions, content)
+
+		if err := os.WriteFile("big.txt", []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile failed %v", err)
+		}
+		addCmd.Run(nil, []string{"big.txt"})
+		saveCmd.Run(nil, []string{fmt.Sprintf("version %d", v)})
+	}
+
+	result, err := packLooseObjects()
+	if err != nil {
+		t.Fatalf("packLooseObjects failed: %v", err)
+	}
+
+	t.Run("loose objects are bundled", func(t *testing.T) {
+		loose, err := utils.ListLooseObjects()
+		if err != nil {
+			t.Fatalf("ListLooseObjects failed: %v", err)
+		}
+		if len(loose) != 0 {
+			t.Errorf("Expected no loose objects after packing, got %d", len(loose))
+		}
+
+		packs, err := utils.ListPacks()
+		if err != nil || len(packs) != 1 {
+			t.Fatalf("Expected one pack, got %v (%v)", packs, err)
+		}
+		hashes, err := utils.ListPackObjects(packs[0])
+		if err != nil {
+			t.Fatalf("ListPackObjects failed: %v", err)
+		}
+		if len(hashes) != result.Objects {
+			t.Errorf("Expected %d objects in the index, got %d", result.Objects, len(hashes))
+		}
+	})
+
+	t.Run("similar blobs are stored as deltas", func(t *testing.T) {
+		if result.Deltas == 0 {
+			t.Errorf("Expected some objects to be delta compressed")
+		}
+		if result.Size >= int64(len(versions[0])) {
+			t.Errorf("Expected pack (%d bytes) to be smaller than a single version (%d bytes)", result.Size, len(versions[0]))
+		}
+	})
+
+	t.Run("packed objects read transparently", func(t *testing.T) {
+		current := getHead()
+		for v := len(versions) - 1; v >= 0; v-- {
+			commit, err := readCommit(current)
+			if err != nil {
+				t.Fatalf("readCommit failed: %v", err)
+			}
+
+			kind, content, err := utils.ReadObject(commit.Files["big.txt"])
+			if err != nil {
+				t.Fatalf("ReadObject failed: %v", err)
+			}
+			if kind != utils.BLOB_OBJECT || string(content) != versions[v] {
+				t.Errorf("Unexpected content for version %d", v)
+			}
+			current = commit.Parent
+		}
+	})
+
+	t.Run("packing the same objects again replaces the pack in place", func(t *testing.T) {
+		objects, err := utils.ListPackObjects(result.Name)
+		if err != nil {
+			t.Fatalf("ListPackObjects failed: %v", err)
+		}
+		var hashes []string
+		for _, object := range objects {
+			hashes = append(hashes, object.Hash)
+		}
+		again, err := utils.WritePack(hashes)
+		if err != nil {
+			t.Fatalf("WritePack failed: %v", err)
+		}
+		if again.Name != result.Name {
+			t.Errorf("Expected the same pack name, got %s and %s", result.Name, again.Name)
+		}
+
+		files, _ := os.ReadDir(filepath.Join(utils.DEFAULT_PATH, "objects", "pack"))
+		if len(files) != 2 {
+			t.Errorf("Expected only the pack and its index, got %v", files)
+		}
+		if _, _, err := utils.ReadObject(getHead()); err != nil {
+			t.Errorf("Expected packed objects to stay readable: %v", err)
+		}
+	})
+}
@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"microgit/utils"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	DEFAULT_BRANCH = "main"
+
+	// HEAD either holds a save point hash (detached) or names the current
+	// branch as "ref: refs/heads/<branch>"
+	symbolicRefPrefix = "ref: "
+	branchRefPrefix   = "refs/heads/"
+	tagRefPrefix      = "refs/tags/"
+)
+
+var fullHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+func branchRef(branch string) string {
+	return branchRefPrefix + branch
+}
+
+func tagRef(tag string) string {
+	return tagRefPrefix + tag
+}
+
+// refPath returns the file a reference such as refs/heads/main is stored
+// in. Names that are not clean paths below refs/ have none, so no name can
+// lead to a file elsewhere in the repository.
+func refPath(name string) (string, error) {
+	if !strings.HasPrefix(name, "refs/") || path.Clean(name) != name || strings.Contains(name, "\\") {
+		return "", fmt.Errorf("invalid reference name %q", name)
+	}
+	return filepath.Join(utils.DEFAULT_PATH, filepath.FromSlash(name)), nil
+}
+
+// readRef returns the hash stored in a reference such as refs/heads/main
+func readRef(name string) string {
+	file, err := refPath(name)
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// updateRef points a reference at a save point
+func updateRef(name, hash string) error {
+	file, err := refPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, []byte(hash), 0644)
+}
+
+// deleteRef removes a reference
+func deleteRef(name string) error {
+	file, err := refPath(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
+
+// refExists reports whether a reference file exists
+func refExists(name string) bool {
+	file, err := refPath(name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(file)
+	return err == nil
+}
+
+// listRefs returns every reference below the prefix (e.g. refs/heads/) as
+// name -> hash, with the prefix stripped from the names
+func listRefs(prefix string) (map[string]string, error) {
+	refs := make(map[string]string)
+	root := filepath.Join(utils.DEFAULT_PATH, filepath.FromSlash(prefix))
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		refs[filepath.ToSlash(name)] = readRef(prefix + filepath.ToSlash(name))
+		return nil
+	})
+
+	return refs, err
+}
+
+// listBranches returns the sorted names of every branch
+func listBranches() ([]string, error) {
+	refs, err := listRefs(branchRefPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var branches []string
+	for name := range refs {
+		branches = append(branches, name)
+	}
+	sort.Strings(branches)
+	return branches, nil
+}
+
+// validBranchName reports whether name can be used as a branch
+func validBranchName(name string) error {
+	return validRefName("branch", name)
+}
+
+// validRefName reports whether name can be used for a ref of the given
+// kind, such as a branch or a tag
+func validRefName(kind, name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%s name cannot be empty", kind)
+	case name == "HEAD" || name == "latest":
+		return fmt.Errorf("%q is reserved", name)
+	case strings.HasPrefix(name, "-") || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		return fmt.Errorf("invalid %s name %q", kind, name)
+	case strings.Contains(name, "..") || strings.Contains(name, "//"):
+		return fmt.Errorf("invalid %s name %q", kind, name)
+	case strings.ContainsAny(name, " \t\n~^:?*[\\") || strings.Contains(name, "@{") || name == "@":
+		return fmt.Errorf("invalid %s name %q", kind, name)
+	case fullHashPattern.MatchString(name):
+		return fmt.Errorf("%s name %q looks like a save point hash", kind, name)
+	}
+	return nil
+}
+
+// readHeadFile returns the raw contents of HEAD
+func readHeadFile() string {
+	headPath := filepath.Join(utils.DEFAULT_PATH, "HEAD")
+
+	data, err := os.ReadFile(headPath)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// currentBranch returns the branch HEAD points at, or "" when HEAD is detached
+func currentBranch() string {
+	head := readHeadFile()
+	if ref, ok := strings.CutPrefix(head, symbolicRefPrefix); ok {
+		return strings.TrimPrefix(ref, branchRefPrefix)
+	}
+	return ""
+}
+
+// attachHead makes branch the current branch
+func attachHead(branch string) error {
+	headPath := filepath.Join(utils.DEFAULT_PATH, "HEAD")
+	return os.WriteFile(headPath, []byte(symbolicRefPrefix+branchRef(branch)), 0644)
+}
+
+// detachHead points HEAD directly at a save point
+func detachHead(hash string) error {
+	if branch := currentBranch(); branch != "" {
+		origPath := filepath.Join(utils.DEFAULT_PATH, "ORIG_BRANCH")
+		if err := os.WriteFile(origPath, []byte(branch), 0644); err != nil {
+			return err
+		}
+	}
+
+	headPath := filepath.Join(utils.DEFAULT_PATH, "HEAD")
+	return os.WriteFile(headPath, []byte(hash), 0644)
+}
+
+// latestBranch returns the branch "latest" refers to: the current branch,
+// or the branch that was checked out before HEAD was detached
+func latestBranch() string {
+	if branch := currentBranch(); branch != "" {
+		return branch
+	}
+
+	data, err := os.ReadFile(filepath.Join(utils.DEFAULT_PATH, "ORIG_BRANCH"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// migrateRefs converts a repository that tracks history with the HEAD and
+// LATEST files into one with a default branch
+func migrateRefs() error {
+	latestPath := filepath.Join(utils.DEFAULT_PATH, "LATEST")
+	data, err := os.ReadFile(latestPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	latest := strings.TrimSpace(string(data))
+	head := readHeadFile()
+
+	if latest == "" {
+		latest = head
+	}
+	if latest != "" {
+		if err := updateRef(branchRef(DEFAULT_BRANCH), latest); err != nil {
+			return err
+		}
+	}
+
+	// HEAD stays detached if it was left on an older save point
+	if head == "" || head == latest {
+		if err := attachHead(DEFAULT_BRANCH); err != nil {
+			return err
+		}
+	} else {
+		origPath := filepath.Join(utils.DEFAULT_PATH, "ORIG_BRANCH")
+		if err := os.WriteFile(origPath, []byte(DEFAULT_BRANCH), 0644); err != nil {
+			return err
+		}
+	}
+
+	return os.Remove(latestPath)
+}
+
+// ancestors returns every save point reachable from the given ones,
+// including themselves, stopping at save points already in seen
+func ancestors(seen map[string]bool, hashes ...string) map[string]bool {
+	found := make(map[string]bool)
+	queue := append([]string(nil), hashes...)
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == "" || found[current] || seen[current] {
+			continue
+		}
+		found[current] = true
+
+		commit, err := readCommit(current)
+		if err != nil {
+			continue
+		}
+		queue = append(queue, commit.Parents...)
+	}
+	return found
+}
+
+// orphanedSavePoints returns the save points, newest first, that are only
+// reachable from hash and would be lost once nothing points at it anymore
+func orphanedSavePoints(hash string) []string {
+	var roots []string
+	for _, root := range gcRoots() {
+		if savePoint, err := peelTag(root); err == nil {
+			roots = append(roots, savePoint)
+		}
+	}
+	reachable := ancestors(nil, roots...)
+
+	var orphaned []string
+	timestamps := make(map[string]string)
+	for savePoint := range ancestors(reachable, hash) {
+		commit, _ := readCommit(savePoint)
+		timestamps[savePoint] = commit.Timestamp
+		orphaned = append(orphaned, savePoint)
+	}
+	sort.Slice(orphaned, func(i, j int) bool {
+		if timestamps[orphaned[i]] != timestamps[orphaned[j]] {
+			return timestamps[orphaned[i]] > timestamps[orphaned[j]]
+		}
+		return orphaned[i] < orphaned[j]
+	})
+	return orphaned
+}
+
+// warnOrphaned tells the user how to recover save points that are no longer
+// reachable from HEAD or any ref after hash stopped being referenced. The
+// hash may name an annotated tag.
+func warnOrphaned(hash string) {
+	if hash == "" {
+		return
+	}
+	if savePoint, err := peelTag(hash); err == nil {
+		hash = savePoint
+	}
+
+	orphaned := orphanedSavePoints(hash)
+	if len(orphaned) == 0 {
+		return
+	}
+
+	fmt.Printf("Warning: you are leaving %d save point(s) behind, not connected to any branch:\n", len(orphaned))
+	for _, savePoint := range orphaned {
+		commit, _ := readCommit(savePoint)
+		fmt.Printf("  %s %s\n", savePoint, commit.Message)
+	}
+	fmt.Printf("To keep them, create a branch now:\n  microgit branch <name> %s\n", hash)
+}
@@ -2,14 +2,22 @@ package cmd
 
 import (
 	"fmt"
-	"microgit/utils"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+// unstage puts the index entry for file back to its state at HEAD: the
+// saved version for a tracked file, or no entry for a new one
+func unstage(index map[string]string, file string, committed map[string]string) {
+	if hash, ok := committed[file]; ok {
+		index[file] = hash
+	} else {
+		delete(index, file)
+	}
+}
+
 // removeCmd represents the remove command
 var removeCmd = &cobra.Command{
 	Use:   "remove",
@@ -21,7 +29,8 @@ Usage:
   microgit remove .                    - Remove all files from staging
 
 This command will:
-1. Remove the specified files from the index
+1. Put the index entries of the files back to their state at HEAD, so
+   files that were never saved are no longer tracked
 2. Keep the files in your working directory
 3. Allow you to re-stage them later if needed`,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -30,37 +39,27 @@ This command will:
 			return
 		}
 
+		committed := getCommittedFiles()
+
 		if args[0] == "." {
-			indexPath := filepath.Join(utils.DEFAULT_PATH, "index")
-			err := os.WriteFile(indexPath, []byte(""), 0644)
-			if err != nil {
+			if err := writeIndex(committed); err != nil {
 				fmt.Printf("Failed to unstage files: %v", err)
-				return
 			}
+			return
+		}
 
+		index, err := readIndex()
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Println("Failed to read index file", err)
 			return
 		}
 
 		for _, file := range args {
-			indexPath := filepath.Join(utils.DEFAULT_PATH, "index")
-
-			data, err := os.ReadFile(indexPath)
-			if err != nil {
-				fmt.Println("Failed to read index file", err)
-			}
-
-			lines := strings.Split(string(data), "\n")
-
-			var stagedFiles []string
-
-			for _, line := range lines {
-				if !strings.HasPrefix(line, file) {
-					stagedFiles = append(stagedFiles, line)
-				}
-			}
+			unstage(index, filepath.ToSlash(filepath.Clean(file)), committed)
+		}
 
-			newIndex := strings.Trim(strings.Join(stagedFiles, "\n"), "\n")
-			os.WriteFile(indexPath, []byte(newIndex), 0644)
+		if err := writeIndex(index); err != nil {
+			fmt.Printf("Failed to unstage files: %v", err)
 		}
 	},
 }
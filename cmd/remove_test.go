@@ -3,7 +3,7 @@ package cmd
 import (
 	"microgit/utils"
 	"os"
-	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -32,44 +32,56 @@ func TestRemoveCmd(t *testing.T) {
 
 	initCmd.Run(nil, nil)
 
-	// Create test index file
-	indexPath := filepath.Join(utils.DEFAULT_PATH, "index")
-	testContent := "file1.txt\nfile2.txt\nfile3.txt"
-	if err := os.WriteFile(indexPath, []byte(testContent), 0644); err != nil {
+	// Create test index
+	testIndex := make(map[string]string)
+	for _, path := range []string{"file1.txt", "file2.txt", "file3.txt", "foo", "foo.go", "my file.txt"} {
+		testIndex[path] = utils.HashObject(utils.BLOB_OBJECT, []byte(path))
+	}
+	if err := writeIndex(testIndex); err != nil {
 		t.Fatalf("Failed to update test index: %v", err)
 	}
 
 	tests := []struct {
 		name          string
 		args          []string
-		expectedIndex string
+		expectedIndex []string
 	}{
 		{
 			name:          "Remove single file",
 			args:          []string{"file1.txt"},
-			expectedIndex: "file2.txt\nfile3.txt",
+			expectedIndex: []string{"file2.txt", "file3.txt", "foo", "foo.go", "my file.txt"},
 		},
 		{
 			name:          "Remove multiple files",
 			args:          []string{"file2.txt", "file3.txt"},
-			expectedIndex: "file1.txt",
+			expectedIndex: []string{"file1.txt", "foo", "foo.go", "my file.txt"},
 		},
 		{
 			name:          "Remove all files with dot",
 			args:          []string{"."},
-			expectedIndex: "",
+			expectedIndex: nil,
 		},
 		{
 			name:          "Remove non-existent file",
 			args:          []string{"nonexistent.txt"},
-			expectedIndex: "file1.txt\nfile2.txt\nfile3.txt",
+			expectedIndex: []string{"file1.txt", "file2.txt", "file3.txt", "foo", "foo.go", "my file.txt"},
+		},
+		{
+			name:          "Remove only the exact path",
+			args:          []string{"foo"},
+			expectedIndex: []string{"file1.txt", "file2.txt", "file3.txt", "foo.go", "my file.txt"},
+		},
+		{
+			name:          "Remove a path with a space",
+			args:          []string{"my file.txt"},
+			expectedIndex: []string{"file1.txt", "file2.txt", "file3.txt", "foo", "foo.go"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Reset index content before each test
-			if err := os.WriteFile(indexPath, []byte(testContent), 0644); err != nil {
+			if err := writeIndex(testIndex); err != nil {
 				t.Fatalf("Failed to reset index: %v", err)
 			}
 
@@ -77,13 +89,13 @@ func TestRemoveCmd(t *testing.T) {
 			removeCmd.Run(nil, tt.args)
 
 			// Read resulting index
-			got, err := os.ReadFile(indexPath)
+			got, err := readIndex()
 			if err != nil {
 				t.Fatalf("Failed to read index: %v", err)
 			}
 
-			if string(got) != tt.expectedIndex {
-				t.Errorf("Remove command result = %v, want %v", string(got), tt.expectedIndex)
+			if paths := sortedKeys(got); strings.Join(paths, "\n") != strings.Join(tt.expectedIndex, "\n") {
+				t.Errorf("Remove command result = %v, want %v", paths, tt.expectedIndex)
 			}
 		})
 	}
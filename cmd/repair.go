@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"fmt"
+	"microgit/utils"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// repairHistory rewrites every save point reachable from HEAD or a ref so
+// that it records a complete snapshot: save points made by older versions
+// take the files they do not list from their first parent. Save points
+// already marked complete keep their files, so deletions stay deleted. It
+// returns old -> new hashes for every save point that changed.
+func repairHistory() (map[string]string, error) {
+	order := historyOrder(historyRoots()...)
+	rewritten := make(map[string]string)
+	snapshots := make(map[string]map[string]string)
+
+	// Parents are repaired before their children
+	for i := len(order) - 1; i >= 0; i-- {
+		hash := order[i]
+		commit, err := readCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		changed := false
+		parents := make([]string, len(commit.Parents))
+		for j, parent := range commit.Parents {
+			parents[j] = parent
+			if replacement, ok := rewritten[parent]; ok {
+				parents[j] = replacement
+				changed = true
+			}
+		}
+
+		files := make(map[string]string)
+		if len(parents) > 0 && !commit.Complete {
+			for path, blob := range snapshots[parents[0]] {
+				files[path] = blob
+			}
+		}
+		for path, blob := range commit.Files {
+			files[path] = blob
+		}
+		if !sameFiles(files, commit.Files) {
+			changed = true
+		}
+
+		if !changed {
+			snapshots[hash] = commit.Files
+			continue
+		}
+
+		// Everything but the snapshot and parents stays as it was, including
+		// who made the save point and when
+		commit.Files = files
+		commit.Parents = parents
+		commit.Parent = ""
+		commit.Tree = ""
+		commit.Complete = true
+		repaired, err := storeSavePoint(commit)
+		if err != nil {
+			return nil, err
+		}
+		rewritten[hash] = repaired
+		snapshots[repaired] = files
+	}
+
+	if err := rewriteRefs(rewritten); err != nil {
+		return rewritten, err
+	}
+
+	// Files the repaired HEAD gained are tracked from now on
+	if head := getHead(); head != "" {
+		index, err := readIndex()
+		if err != nil && !os.IsNotExist(err) {
+			return rewritten, err
+		}
+		files := make(map[string]string)
+		for path, blob := range snapshots[head] {
+			files[path] = blob
+		}
+		for path, blob := range index {
+			files[path] = blob
+		}
+		if err := writeIndex(files); err != nil {
+			return rewritten, err
+		}
+	}
+	return rewritten, nil
+}
+
+// fullSnapshot returns every file tracked at a save point. Save points made
+// by older versions list only the files staged for them, so the rest are
+// taken from their first parents the way repairHistory fills them in.
+func fullSnapshot(hash string) (map[string]string, error) {
+	files := make(map[string]string)
+	for hash != "" {
+		commit, err := readCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		for path, blob := range commit.Files {
+			if _, ok := files[path]; !ok {
+				files[path] = blob
+			}
+		}
+		if commit.Complete {
+			break
+		}
+		hash = commit.Parent
+	}
+	return files, nil
+}
+
+// historyRoots returns the save points that HEAD, every ref and MERGE_HEAD
+// lead to, with annotated tags peeled
+func historyRoots() []string {
+	var roots []string
+	for _, root := range gcRoots() {
+		if hash, err := peelTag(root); err == nil {
+			roots = append(roots, hash)
+		}
+	}
+	return roots
+}
+
+// rewriteRefs points every ref, a detached HEAD and MERGE_HEAD at the
+// rewritten save points. Annotated tags are recreated with the new target.
+func rewriteRefs(rewritten map[string]string) error {
+	refs, err := listRefs("refs/")
+	if err != nil {
+		return err
+	}
+
+	for _, name := range sortedKeys(refs) {
+		hash := refs[name]
+		if replacement, ok := rewritten[hash]; ok {
+			if err := updateRef("refs/"+name, replacement); err != nil {
+				return err
+			}
+			continue
+		}
+
+		kind, _, err := utils.ReadObject(hash)
+		if err != nil || kind != utils.TAG_OBJECT {
+			continue
+		}
+		tag, err := utils.ReadTag(hash)
+		if err != nil {
+			return err
+		}
+		replacement, ok := rewritten[tag.Target]
+		if !ok {
+			continue
+		}
+		tag.Target = replacement
+		tagHash, err := utils.WriteTag(tag)
+		if err != nil {
+			return err
+		}
+		if err := updateRef("refs/"+name, tagHash); err != nil {
+			return err
+		}
+	}
+
+	if currentBranch() == "" {
+		if replacement, ok := rewritten[getHead()]; ok {
+			if err := setHead(replacement); err != nil {
+				return err
+			}
+		}
+	}
+	if replacement, ok := rewritten[readMergeHead()]; ok {
+		if err := os.WriteFile(mergeHeadPath(), []byte(replacement), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repairHistoryCmd represents the repair-history command
+var repairHistoryCmd = &cobra.Command{
+	Use:   "repair-history",
+	Short: "Rebuild complete snapshots for save points made by older versions",
+	Long: `Older versions of MicroGit saved only the files staged for each save,
+so checking out such a save point restores just those files. This command
+rewrites the history so every save point records the complete project:
+each one made by an older version keeps its own files and takes every other
+file from its parent. Save points that already record the complete project
+are only rewritten when their parents are, so deleted files stay deleted.
+
+Messages, dates, authors and committers are kept. Rewritten save points get
+new hashes; branches, tags and HEAD are moved to them, and the old ones are
+listed so they can still be found until microgit gc removes them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		rewritten, err := repairHistory()
+		if err != nil {
+			fmt.Printf("Error repairing history: %v\n", err)
+			return
+		}
+
+		if len(rewritten) == 0 {
+			fmt.Println("History already records complete snapshots")
+			return
+		}
+		for _, old := range sortedKeys(rewritten) {
+			fmt.Printf("%s -> %s\n", old, rewritten[old])
+		}
+		fmt.Printf("Rewrote %d save point(s)\n", len(rewritten))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(repairHistoryCmd)
+}
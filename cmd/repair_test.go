@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"microgit/utils"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRepairHistory(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	blob := func(content string) string {
+		hash, err := utils.WriteObject(utils.BLOB_OBJECT, []byte(content))
+		if err != nil {
+			t.Fatalf("WriteObject failed: %v", err)
+		}
+		return hash
+	}
+
+	// Older versions recorded only the files staged for each save
+	legacy := func(message string, files map[string]string, parents ...string) string {
+		hash, err := storeSavePoint(utils.SavePoint{
+			Message:   message,
+			Timestamp: time.Now().Format(time.RFC3339),
+			Parents:   parents,
+			Files:     files,
+		})
+		if err != nil {
+			t.Fatalf("storeSavePoint failed: %v", err)
+		}
+		return hash
+	}
+
+	first := legacy("first", map[string]string{"a.txt": blob("a"), "b.txt": blob("b")})
+	second := legacy("second", map[string]string{"a.txt": blob("a2")}, first)
+	third := legacy("third", map[string]string{"c.txt": blob("c")}, second)
+	setHead(third)
+	if err := createTag("v1", second, "release", true, false); err != nil {
+		t.Fatalf("createTag failed: %v", err)
+	}
+
+	rewritten, err := repairHistory()
+	if err != nil {
+		t.Fatalf("repairHistory failed: %v", err)
+	}
+
+	t.Run("save points record complete snapshots", func(t *testing.T) {
+		if _, ok := rewritten[first]; ok {
+			t.Errorf("Expected the complete first save point to be kept")
+		}
+
+		commit, err := readCommit(getHead())
+		if err != nil {
+			t.Fatalf("readCommit failed: %v", err)
+		}
+		want := map[string]string{"a.txt": blob("a2"), "b.txt": blob("b"), "c.txt": blob("c")}
+		if !sameFiles(commit.Files, want) {
+			t.Errorf("Expected %v, got %v", want, commit.Files)
+		}
+		if commit.Message != "third" || commit.Author() != "" {
+			t.Errorf("Expected the message and missing identity to be kept, got %q by %q", commit.Message, commit.Author())
+		}
+		if getHead() != rewritten[third] || commit.Parent != rewritten[second] {
+			t.Errorf("Expected the branch to follow the rewritten history")
+		}
+	})
+
+	t.Run("annotated tags follow the rewrite", func(t *testing.T) {
+		hash, err := resolveRevision("v1")
+		if err != nil || hash != rewritten[second] {
+			t.Errorf("Expected v1 at %s, got %s (%v)", rewritten[second], hash, err)
+		}
+		tag, _ := utils.ReadTag(readRef(tagRef("v1")))
+		if tag.Message != "release" {
+			t.Errorf("Expected the tag message to be kept, got %q", tag.Message)
+		}
+	})
+
+	t.Run("the index tracks the repaired files", func(t *testing.T) {
+		index, _ := readIndex()
+		if len(index) != 3 {
+			t.Errorf("Expected 3 tracked files, got %v", index)
+		}
+	})
+
+	t.Run("repairing again changes nothing", func(t *testing.T) {
+		again, err := repairHistory()
+		if err != nil || len(again) != 0 {
+			t.Errorf("Expected nothing to rewrite, got %v (%v)", again, err)
+		}
+	})
+
+	t.Run("deleted files stay deleted", func(t *testing.T) {
+		for path, content := range map[string]string{"a.txt": "a2", "b.txt": "b", "c.txt": "c"} {
+			os.WriteFile(path, []byte(content), 0644)
+		}
+		if _, err := removeFiles([]string{"b.txt"}, false, false); err != nil {
+			t.Fatalf("removeFiles failed: %v", err)
+		}
+		saveCmd.Run(nil, []string{"three"})
+		head := getHead()
+
+		again, err := repairHistory()
+		if err != nil || len(again) != 0 {
+			t.Errorf("Expected nothing to rewrite, got %v (%v)", again, err)
+		}
+		commit, err := readCommit(getHead())
+		if err != nil || getHead() != head {
+			t.Fatalf("Expected HEAD to stay at %s, got %s (%v)", head, getHead(), err)
+		}
+		if _, ok := commit.Files["b.txt"]; ok || len(commit.Files) != 2 {
+			t.Errorf("Expected b.txt to stay deleted, got %v", commit.Files)
+		}
+	})
+}
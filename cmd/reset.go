@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	resetSoft  bool
+	resetMixed bool
+	resetHard  bool
+)
+
+// resetTo moves HEAD to the save point rev resolves to. The index is
+// reloaded from it unless soft is set, and the working tree too if hard is
+func resetTo(rev string, soft, hard bool) error {
+	hash, err := resolveRevision(rev)
+	if err != nil {
+		return err
+	}
+	target, err := readCommit(hash)
+	if err != nil {
+		return err
+	}
+
+	if hard {
+		// Tracked files are rewritten from what is on disk now, so unsaved
+		// edits are discarded as well
+		current, err := trackedWorkingFiles()
+		if err != nil {
+			return err
+		}
+		if err := updateWorkingTree(current, target.Files); err != nil {
+			return err
+		}
+	}
+
+	if !soft {
+		if err := writeIndex(target.Files); err != nil {
+			return fmt.Errorf("failed to write index: %w", err)
+		}
+		// The reloaded index no longer holds a half-finished merge
+		clearMergeState()
+	}
+
+	previous := getHead()
+	if err := setHead(hash); err != nil {
+		return err
+	}
+	if previous != hash {
+		warnOrphaned(previous)
+	}
+	return nil
+}
+
+// resetPaths restores the index entries selected by the given pathspecs to
+// their state at HEAD
+func resetPaths(paths []string) error {
+	index, err := readIndex()
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	committed := getCommittedFiles()
+
+	for _, prefix := range paths {
+		matched := false
+		for path := range index {
+			if matchPathspec(path, prefix) {
+				delete(index, path)
+				matched = true
+			}
+		}
+		for path, hash := range committed {
+			if matchPathspec(path, prefix) {
+				index[path] = hash
+				matched = true
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s did not match any tracked file", prefix)
+		}
+	}
+
+	if err := writeIndex(index); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
+
+// isTrackedPath reports whether spec selects a file that is staged or saved
+// at HEAD
+func isTrackedPath(spec string) bool {
+	index, _ := readIndex()
+	for _, files := range []map[string]string{index, getCommittedFiles()} {
+		for path := range files {
+			if matchPathspec(path, spec) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resetCmd represents the reset command
+var resetCmd = &cobra.Command{
+	Use:   "reset [--soft | --mixed | --hard] [<rev>] | reset [--] <paths...>",
+	Short: "Move HEAD to another save point or unstage files",
+	Long: `Move the current branch (or a detached HEAD) to another save point,
+or restore individual index entries from HEAD.
+
+Usage:
+  microgit reset --soft <rev>   - Move HEAD only; the index and files are kept
+  microgit reset [--mixed] <rev> - Also reload the index from the save point
+  microgit reset --hard <rev>   - Also rewrite the working tree, discarding unsaved changes
+  microgit reset <paths...>     - Restore the index entries of paths from HEAD
+  microgit reset -- <paths...>  - The same, for paths that look like revisions
+
+Without a revision, reset resets to HEAD. A single argument that is both a
+tracked path and a revision is taken as the path. Save points that are no longer
+reachable from any branch afterwards are listed along with the command that
+keeps them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		modes := 0
+		for _, set := range []bool{resetSoft, resetMixed, resetHard} {
+			if set {
+				modes++
+			}
+		}
+		if modes > 1 {
+			fmt.Println("Error: --soft, --mixed and --hard cannot be combined")
+			return
+		}
+
+		// Everything after -- is a path. Otherwise a single argument that
+		// names a save point is a revision, unless it is also a tracked path,
+		// and anything else is a list of paths.
+		rev := "HEAD"
+		dash := -1
+		if cmd != nil {
+			dash = cmd.ArgsLenAtDash()
+		}
+		switch {
+		case dash > 0:
+			fmt.Println("Error: paths are restored from HEAD; a revision cannot come before --")
+			return
+		case dash == 0:
+			if len(args) == 0 {
+				fmt.Println("Error: No paths specified after --")
+				return
+			}
+		case len(args) == 1 && !isTrackedPath(args[0]):
+			if _, err := resolveRevision(args[0]); err == nil {
+				rev = args[0]
+				args = nil
+			}
+		}
+
+		if len(args) > 0 {
+			if modes > 0 {
+				fmt.Println("Error: cannot use --soft, --mixed or --hard with paths")
+				return
+			}
+			if err := resetPaths(args); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			return
+		}
+
+		if getHead() == "" {
+			fmt.Println("Error: nothing has been saved yet")
+			return
+		}
+		if err := resetTo(rev, resetSoft, resetHard); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("HEAD is now at %s\n", getHead())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resetCmd)
+
+	resetCmd.Flags().BoolVar(&resetSoft, "soft", false, "Move HEAD only")
+	resetCmd.Flags().BoolVar(&resetMixed, "mixed", false, "Move HEAD and reload the index (default)")
+	resetCmd.Flags().BoolVar(&resetHard, "hard", false, "Move HEAD and reload the index and working tree")
+}
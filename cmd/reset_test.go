@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+)
+
+func TestResetCmd(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	defer func() { resetSoft, resetMixed, resetHard = false, false, false }()
+
+	save := func(files map[string]string, message string) string {
+		for path, content := range files {
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("WriteFile failed %v", err)
+			}
+		}
+		addCmd.Run(nil, []string{"."})
+		saveCmd.Run(nil, []string{message})
+		return getHead()
+	}
+
+	first := save(map[string]string{"a.txt": "one\n"}, "first")
+	second := save(map[string]string{"a.txt": "two\n", "b.txt": "b\n"}, "second")
+
+	t.Run("soft moves HEAD only", func(t *testing.T) {
+		resetSoft = true
+		resetCmd.Run(nil, []string{first})
+		resetSoft = false
+
+		if getHead() != first {
+			t.Errorf("Expected HEAD at %s, got %s", first, getHead())
+		}
+		if content, _ := os.ReadFile("a.txt"); string(content) != "two\n" {
+			t.Errorf("Expected the working tree to be kept, got %q", content)
+		}
+
+		resetCmd.Run(nil, []string{second})
+	})
+
+	t.Run("mixed reloads the index", func(t *testing.T) {
+		resetCmd.Run(nil, []string{first})
+
+		index, err := readIndex()
+		if err != nil {
+			t.Fatalf("readIndex failed: %v", err)
+		}
+		commit, _ := readCommit(first)
+		if len(index) != 1 || index["a.txt"] != commit.Files["a.txt"] {
+			t.Errorf("Expected the index to match %s, got %v", first, index)
+		}
+		if _, err := os.Stat("b.txt"); err != nil {
+			t.Errorf("Expected the working tree to be kept")
+		}
+
+		resetCmd.Run(nil, []string{second})
+	})
+
+	t.Run("hard rewrites the working tree", func(t *testing.T) {
+		os.WriteFile("a.txt", []byte("unsaved\n"), 0644)
+
+		resetHard = true
+		resetCmd.Run(nil, []string{first})
+		resetHard = false
+
+		if content, _ := os.ReadFile("a.txt"); string(content) != "one\n" {
+			t.Errorf("Expected a.txt to be restored, got %q", content)
+		}
+		if _, err := os.Stat("b.txt"); !os.IsNotExist(err) {
+			t.Errorf("Expected b.txt to be removed")
+		}
+
+		resetHard = true
+		resetCmd.Run(nil, []string{second})
+		resetHard = false
+	})
+
+	t.Run("paths restore index entries from HEAD", func(t *testing.T) {
+		os.WriteFile("a.txt", []byte("staged\n"), 0644)
+		addCmd.Run(nil, []string{"a.txt"})
+
+		resetCmd.Run(nil, []string{"a.txt"})
+
+		index, _ := readIndex()
+		commit, _ := readCommit(second)
+		if index["a.txt"] != commit.Files["a.txt"] {
+			t.Errorf("Expected a.txt to be unstaged, got %s", index["a.txt"])
+		}
+		if getHead() != second {
+			t.Errorf("Expected HEAD to stay at %s", second)
+		}
+	})
+
+	t.Run("a tracked file wins over a revision of the same name", func(t *testing.T) {
+		os.WriteFile("feature", []byte("saved\n"), 0644)
+		addCmd.Run(nil, []string{"feature"})
+		saveCmd.Run(nil, []string{"add feature"})
+		head := getHead()
+		branchCmd.Run(nil, []string{"feature", first})
+
+		os.WriteFile("feature", []byte("staged\n"), 0644)
+		addCmd.Run(nil, []string{"feature"})
+		resetCmd.Run(nil, []string{"feature"})
+
+		commit, _ := readCommit(head)
+		if index, _ := readIndex(); index["feature"] != commit.Files["feature"] {
+			t.Errorf("Expected feature to be unstaged, got %s", index["feature"])
+		}
+		if getHead() != head {
+			t.Errorf("Expected HEAD to stay at %s, got %s", head, getHead())
+		}
+	})
+
+	t.Run("paths after -- are never revisions", func(t *testing.T) {
+		head := getHead()
+		os.WriteFile("feature", []byte("staged again\n"), 0644)
+		addCmd.Run(nil, []string{"feature"})
+
+		// Parsing the flags records where -- was
+		if err := resetCmd.Flags().Parse([]string{"--", "feature"}); err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		resetCmd.Run(resetCmd, resetCmd.Flags().Args())
+
+		commit, _ := readCommit(head)
+		if index, _ := readIndex(); index["feature"] != commit.Files["feature"] {
+			t.Errorf("Expected feature to be unstaged, got %s", index["feature"])
+		}
+		if getHead() != head {
+			t.Errorf("Expected HEAD to stay at %s, got %s", head, getHead())
+		}
+	})
+}
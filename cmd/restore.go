@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	restoreSource   string
+	restoreStaged   bool
+	restoreWorktree bool
+)
+
+// matchPathspec reports whether a repository path is selected by spec: the
+// path itself, a directory containing it, or a glob pattern. Patterns
+// without a slash are also tried against the file name alone
+func matchPathspec(file, spec string) bool {
+	spec = filepath.ToSlash(filepath.Clean(spec))
+	if spec == "." || file == spec || strings.HasPrefix(file, spec+"/") {
+		return true
+	}
+
+	if matched, _ := path.Match(spec, file); matched {
+		return true
+	}
+	if !strings.Contains(spec, "/") {
+		matched, _ := path.Match(spec, path.Base(file))
+		return matched
+	}
+	return false
+}
+
+// restoreFiles copies every file of the source save point selected by
+// specs into the index and/or the working tree. With staged, index entries
+// selected by specs that the source does not have are removed, so new files
+// are unstaged the way remove does it. The working tree copies are kept.
+func restoreFiles(source string, specs []string, staged, worktree bool) ([]string, error) {
+	hash, err := resolveRevision(source)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := readCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]string)
+	if staged {
+		if index, err = readIndex(); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	selected := make(map[string]string)
+	for _, spec := range specs {
+		if _, err := path.Match(spec, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", spec, err)
+		}
+
+		matched := false
+		for file, blob := range commit.Files {
+			if matchPathspec(file, spec) {
+				selected[file] = blob
+				matched = true
+			}
+		}
+		for file := range index {
+			if _, ok := commit.Files[file]; !ok && matchPathspec(file, spec) {
+				selected[file] = ""
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("%s did not match any file in %s", spec, source)
+		}
+	}
+
+	restored := sortedKeys(selected)
+	if staged {
+		for _, file := range restored {
+			unstage(index, file, commit.Files)
+		}
+		if err := writeIndex(index); err != nil {
+			return nil, fmt.Errorf("failed to update index: %w", err)
+		}
+	}
+	for _, file := range restored {
+		if worktree && selected[file] != "" {
+			if err := writeWorkingFile(file, selected[file]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return restored, nil
+}
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore [--source <rev>] [--staged] [--worktree] <paths...>",
+	Short: "Restore files from a save point",
+	Long: `Restore individual files to their content in a save point, leaving
+HEAD and every other file alone.
+
+Usage:
+  microgit restore <paths...>                 - Discard working tree changes to the paths
+  microgit restore --staged <paths...>        - Unstage the paths
+  microgit restore --source <rev> <paths...>  - Bring back the paths as they were in <rev>
+
+Files come from HEAD unless --source names another save point. They are
+written to the working tree by default; --staged writes them to the index
+instead, and --staged --worktree to both. With --staged, staged files the
+source does not have are unstaged, and their working copies are kept.
+
+Paths may name files, directories or glob patterns such as "*.txt"; a
+pattern without a slash also matches file names in any directory.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			fmt.Println("Error: No paths specified")
+			return
+		}
+
+		worktree := restoreWorktree || !restoreStaged
+		restored, err := restoreFiles(restoreSource, args, restoreStaged, worktree)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		for _, file := range restored {
+			fmt.Printf("Restored %s\n", file)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+
+	restoreCmd.Flags().StringVarP(&restoreSource, "source", "s", "HEAD", "Save point to restore the files from")
+	restoreCmd.Flags().BoolVarP(&restoreStaged, "staged", "S", false, "Restore the index")
+	restoreCmd.Flags().BoolVarP(&restoreWorktree, "worktree", "W", false, "Restore the working tree (the default without --staged)")
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchPathspec(t *testing.T) {
+	tests := []struct {
+		file, spec string
+		want       bool
+	}{
+		{"a.txt", "a.txt", true},
+		{"docs/a.txt", "docs", true},
+		{"docs/a.txt", "docs/", true},
+		{"docs/a.txt", "doc", false},
+		{"docs/a.txt", ".", true},
+		{"docs/a.txt", "*.txt", true},
+		{"docs/a.txt", "docs/*.txt", true},
+		{"docs/deep/a.txt", "docs/*.txt", false},
+		{"a.go", "*.txt", false},
+	}
+
+	for _, test := range tests {
+		if got := matchPathspec(test.file, test.spec); got != test.want {
+			t.Errorf("matchPathspec(%q, %q) = %v, want %v", test.file, test.spec, got, test.want)
+		}
+	}
+}
+
+func TestRestoreCmd(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	defer func() { restoreSource, restoreStaged, restoreWorktree = "HEAD", false, false }()
+
+	os.MkdirAll("docs", 0755)
+	os.WriteFile("a.txt", []byte("one\n"), 0644)
+	os.WriteFile(filepath.Join("docs", "guide.txt"), []byte("guide one\n"), 0644)
+	addCmd.Run(nil, []string{"."})
+	saveCmd.Run(nil, []string{"first"})
+	first := getHead()
+
+	os.WriteFile("a.txt", []byte("two\n"), 0644)
+	os.WriteFile(filepath.Join("docs", "guide.txt"), []byte("guide two\n"), 0644)
+	addCmd.Run(nil, []string{"."})
+	saveCmd.Run(nil, []string{"second"})
+
+	t.Run("discard working tree changes", func(t *testing.T) {
+		os.WriteFile("a.txt", []byte("unsaved\n"), 0644)
+		restoreCmd.Run(nil, []string{"a.txt"})
+
+		if content, _ := os.ReadFile("a.txt"); string(content) != "two\n" {
+			t.Errorf("Expected a.txt from HEAD, got %q", content)
+		}
+	})
+
+	t.Run("restore a directory from an older save point", func(t *testing.T) {
+		restoreSource = first
+		restoreCmd.Run(nil, []string{"docs"})
+		restoreSource = "HEAD"
+
+		if content, _ := os.ReadFile(filepath.Join("docs", "guide.txt")); string(content) != "guide one\n" {
+			t.Errorf("Expected docs/guide.txt from %s, got %q", first, content)
+		}
+		if content, _ := os.ReadFile("a.txt"); string(content) != "two\n" {
+			t.Errorf("Expected a.txt to be left alone, got %q", content)
+		}
+		if getHead() == first {
+			t.Errorf("Expected HEAD not to move")
+		}
+	})
+
+	t.Run("staged restores only the index", func(t *testing.T) {
+		restoreSource, restoreStaged = first, true
+		restoreCmd.Run(nil, []string{"*.txt"})
+		restoreSource, restoreStaged = "HEAD", false
+
+		commit, _ := readCommit(first)
+		index, _ := readIndex()
+		for _, path := range []string{"a.txt", "docs/guide.txt"} {
+			if index[path] != commit.Files[path] {
+				t.Errorf("Expected %s to be staged from %s", path, first)
+			}
+		}
+		if content, _ := os.ReadFile("a.txt"); string(content) != "two\n" {
+			t.Errorf("Expected the working tree to be left alone, got %q", content)
+		}
+	})
+
+	t.Run("staged unstages files missing from the source", func(t *testing.T) {
+		os.WriteFile("new.txt", []byte("new\n"), 0644)
+		addCmd.Run(nil, []string{"new.txt"})
+
+		restoreStaged = true
+		restoreCmd.Run(nil, []string{"new.txt"})
+		restoreStaged = false
+
+		if _, ok := readIndexEntries(t)["new.txt"]; ok {
+			t.Errorf("Expected new.txt to be removed from the index")
+		}
+		if content, _ := os.ReadFile("new.txt"); string(content) != "new\n" {
+			t.Errorf("Expected the working copy of new.txt to be kept, got %q", content)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"microgit/utils"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func runRevert(rev string) error {
+	if readMergeHead() != "" {
+		return fmt.Errorf("a merge is in progress; save it or run microgit merge --abort")
+	}
+
+	head := getHead()
+	if head == "" {
+		return fmt.Errorf("nothing has been saved yet")
+	}
+	targetHash, err := resolveRevision(rev)
+	if err != nil {
+		return err
+	}
+
+	target, err := readCommit(targetHash)
+	if err != nil {
+		return err
+	}
+	parentFiles := map[string]string{}
+	if target.Parent != "" {
+		parent, err := readCommit(target.Parent)
+		if err != nil {
+			return err
+		}
+		parentFiles = parent.Files
+	}
+
+	ours, err := readCommit(head)
+	if err != nil {
+		return err
+	}
+	if err := checkClean(parentFiles, ours.Files); err != nil {
+		return err
+	}
+
+	// Undoing a save point is a merge of its parent into HEAD, using the
+	// save point itself as the common base
+	labels := utils.MergeLabels{Ours: "HEAD", Theirs: "parent of " + targetHash}
+	result, err := mergeSnapshots(target.Files, ours.Files, parentFiles, labels)
+	if err != nil {
+		return err
+	}
+	if err := applyMergeResult(ours.Files, result); err != nil {
+		return err
+	}
+
+	if len(result.conflicted) > 0 {
+		if err := writeIndex(result.stagedFiles(ours.Files)); err != nil {
+			return err
+		}
+		for _, path := range sortedKeys(result.conflicted) {
+			fmt.Printf("CONFLICT: %s was changed after %s\n", path, targetHash)
+		}
+		fmt.Println("Revert stopped on conflicts; fix them, add the files and run microgit save")
+		return nil
+	}
+
+	if sameFiles(result.files, ours.Files) {
+		fmt.Println("Nothing to revert: HEAD already has none of the changes")
+		return nil
+	}
+
+	hash, err := writeSavePointObject(utils.SavePoint{
+		Message:   fmt.Sprintf("Revert \"%s\"\n\nThis reverts save point %s.", target.Message, targetHash),
+		Timestamp: time.Now().Format(time.RFC3339),
+		Parent:    head,
+		Files:     result.files,
+	})
+	if err != nil {
+		return err
+	}
+	if err := setHead(hash); err != nil {
+		return err
+	}
+	if err := writeIndex(result.files); err != nil {
+		return err
+	}
+
+	fmt.Printf("Reverted %s: %s\n", targetHash, hash)
+	return nil
+}
+
+// sameFiles reports whether two snapshots hold exactly the same files
+func sameFiles(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, hash := range a {
+		if b[path] != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// revertCmd represents the revert command
+var revertCmd = &cobra.Command{
+	Use:   "revert <commit>",
+	Short: "Undo a save point by saving its inverse",
+	Long: `Create a new save point that undoes the changes introduced by an
+earlier one, leaving the history itself untouched.
+
+The changes the save point made relative to its parent (its first parent,
+for a merge) are reversed on top of HEAD. If later save points changed the
+same lines, the conflicting files are written with conflict markers and no
+save point is created: fix them, add the files and run microgit save.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			fmt.Println("Error: No commit specified")
+			return
+		}
+
+		if err := runRevert(args[0]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(revertCmd)
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRevertCmd(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	save := func(files map[string]string, message string) string {
+		for path, content := range files {
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("WriteFile failed %v", err)
+			}
+		}
+		addCmd.Run(nil, []string{"."})
+		saveCmd.Run(nil, []string{message})
+		return getHead()
+	}
+
+	save(map[string]string{"f.txt": "1\n2\n3\n4\n5\n"}, "base")
+	first := save(map[string]string{"f.txt": "A\n2\n3\n4\n5\n", "new.txt": "new\n"}, "change the first line")
+	save(map[string]string{"f.txt": "A\n2\n3\n4\nB\n", "new.txt": "new\n"}, "change the last line")
+
+	t.Run("clean revert creates an inverse save point", func(t *testing.T) {
+		head := getHead()
+		revertCmd.Run(nil, []string{first})
+
+		commit, err := readCommit(getHead())
+		if err != nil {
+			t.Fatalf("readCommit failed: %v", err)
+		}
+		if commit.Parent != head {
+			t.Errorf("Expected the revert to follow %s, got parent %s", head, commit.Parent)
+		}
+		if !strings.HasPrefix(commit.Message, `Revert "change the first line"`) {
+			t.Errorf("Unexpected message %q", commit.Message)
+		}
+
+		content, _ := os.ReadFile("f.txt")
+		if string(content) != "1\n2\n3\n4\nB\n" {
+			t.Errorf("Unexpected reverted content %q", content)
+		}
+		if _, err := os.Stat("new.txt"); !os.IsNotExist(err) {
+			t.Errorf("Expected the file added by the reverted save point to be removed")
+		}
+		if _, ok := commit.Files["new.txt"]; ok {
+			t.Errorf("Expected new.txt to be left out of the revert save point")
+		}
+	})
+
+	t.Run("reverting twice changes nothing", func(t *testing.T) {
+		head := getHead()
+		revertCmd.Run(nil, []string{first})
+		if getHead() != head {
+			t.Errorf("Expected a second revert to create no save point")
+		}
+	})
+
+	t.Run("later changes to the same lines conflict", func(t *testing.T) {
+		edit := save(map[string]string{"f.txt": "X\n2\n3\n4\nB\n"}, "rewrite the first line")
+		save(map[string]string{"f.txt": "Y\n2\n3\n4\nB\n"}, "rewrite it again")
+
+		head := getHead()
+		revertCmd.Run(nil, []string{edit})
+		if getHead() != head {
+			t.Errorf("Expected a conflicting revert to create no save point")
+		}
+
+		content, _ := os.ReadFile("f.txt")
+		if !strings.Contains(string(content), "<<<<<<< HEAD\nY\n=======\n1\n>>>>>>>") {
+			t.Errorf("Expected conflict markers, got %q", content)
+		}
+	})
+}
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"fmt"
+	"microgit/utils"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MIN_PREFIX_LENGTH is the shortest hash prefix accepted as a revision
+const MIN_PREFIX_LENGTH = 4
+
+var (
+	hashPrefixPattern = regexp.MustCompile(`^[0-9a-f]+$`)
+	relativeDate      = regexp.MustCompile(`^(\d+)[ .]?(second|minute|hour|day|week|month|year)s?[ .]ago$`)
+)
+
+// refSearchPath lists where a short ref name is looked up, in order
+var refSearchPath = []string{"refs/", tagRefPrefix, branchRefPrefix}
+
+// resolveRevision turns a revision expression into a save point hash.
+//
+// A revision starts with a name: HEAD (or @), latest, a branch, tag or other
+// ref name, or a full or unique abbreviated save point hash. It may be
+// followed by any number of suffixes:
+//
+//	rev@{date}  the save point rev pointed at on that date, e.g. main@{yesterday}
+//	rev~n       the n-th first-parent ancestor (rev~ is rev~1)
+//	rev^n       the n-th parent (rev^ is rev^1, rev^0 is rev itself)
+func resolveRevision(rev string) (string, error) {
+	if rev == "" {
+		return "", fmt.Errorf("empty revision")
+	}
+
+	end := strings.IndexAny(rev, "~^")
+	if at := strings.Index(rev, "@{"); at >= 0 && (end < 0 || at < end) {
+		end = at
+	}
+	name, suffixes := rev, ""
+	if end >= 0 {
+		name, suffixes = rev[:end], rev[end:]
+	}
+
+	hash, err := resolveName(name)
+	if err != nil {
+		return "", err
+	}
+	// Annotated tags stand for the save point they point at
+	if hash, err = peelTag(hash); err != nil {
+		return "", err
+	}
+
+	for suffixes != "" {
+		switch suffixes[0] {
+		case '@':
+			if !strings.HasPrefix(suffixes, "@{") {
+				return "", fmt.Errorf("invalid revision %q", rev)
+			}
+			closing := strings.Index(suffixes, "}")
+			if closing < 0 {
+				return "", fmt.Errorf("unterminated %q in %q", suffixes, rev)
+			}
+			if hash, err = savePointAtDate(hash, suffixes[2:closing]); err != nil {
+				return "", fmt.Errorf("%s: %w", rev, err)
+			}
+			suffixes = suffixes[closing+1:]
+
+		case '~', '^':
+			op := suffixes[0]
+			digits := 1
+			for digits < len(suffixes) && suffixes[digits] >= '0' && suffixes[digits] <= '9' {
+				digits++
+			}
+			n := 1
+			if digits > 1 {
+				if n, err = strconv.Atoi(suffixes[1:digits]); err != nil {
+					return "", fmt.Errorf("invalid revision %q", rev)
+				}
+			}
+
+			if op == '~' {
+				hash, err = nthAncestor(hash, n)
+			} else {
+				hash, err = nthParent(hash, n)
+			}
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", rev, err)
+			}
+			suffixes = suffixes[digits:]
+
+		default:
+			return "", fmt.Errorf("invalid revision %q", rev)
+		}
+	}
+	return hash, nil
+}
+
+// resolveName resolves the name a revision starts with
+func resolveName(name string) (string, error) {
+	switch name {
+	case "HEAD", "@", "":
+		if head := getHead(); head != "" {
+			return head, nil
+		}
+		return "", fmt.Errorf("HEAD does not point at a save point yet")
+
+	case "latest":
+		branch := latestBranch()
+		if hash := readRef(branchRef(branch)); branch != "" && hash != "" {
+			return hash, nil
+		}
+		return "", fmt.Errorf("no latest save point")
+	}
+
+	if !strings.Contains(name, "..") {
+		if strings.HasPrefix(name, "refs/") {
+			if hash := readRef(name); hash != "" {
+				return hash, nil
+			}
+		}
+		for _, prefix := range refSearchPath {
+			if hash := readRef(prefix + name); hash != "" {
+				return hash, nil
+			}
+		}
+	}
+
+	if fullHashPattern.MatchString(name) {
+		if !utils.HasObject(name) {
+			return "", fmt.Errorf("unknown revision %q", name)
+		}
+		return name, nil
+	}
+	if len(name) >= MIN_PREFIX_LENGTH && hashPrefixPattern.MatchString(name) {
+		return resolvePrefix(name)
+	}
+
+	return "", fmt.Errorf("unknown revision %q", name)
+}
+
+// resolvePrefix finds the one object whose hash starts with prefix. Save
+// points win when the prefix is shared with other kinds of object.
+func resolvePrefix(prefix string) (string, error) {
+	matches, err := utils.FindObjects(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	if len(matches) > 1 {
+		var savePoints []string
+		for _, hash := range matches {
+			if kind, _, err := utils.ReadObject(hash); err == nil && kind == utils.SAVEPOINT_OBJECT {
+				savePoints = append(savePoints, hash)
+			}
+		}
+		if len(savePoints) > 0 {
+			matches = savePoints
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("unknown revision %q", prefix)
+	case 1:
+		return matches[0], nil
+	}
+
+	var candidates []string
+	for _, hash := range matches {
+		kind, _, _ := utils.ReadObject(hash)
+		candidates = append(candidates, fmt.Sprintf("  %s %s", hash, kind))
+	}
+	return "", fmt.Errorf("short hash %s is ambiguous; candidates are:\n%s", prefix, strings.Join(candidates, "\n"))
+}
+
+// nthParent returns the n-th parent of a save point; the 0th is itself
+func nthParent(hash string, n int) (string, error) {
+	if n == 0 {
+		return hash, nil
+	}
+
+	commit, err := readCommit(hash)
+	if err != nil {
+		return "", err
+	}
+	if n > len(commit.Parents) {
+		if len(commit.Parents) == 0 {
+			return "", fmt.Errorf("save point %s has no parent", hash)
+		}
+		return "", fmt.Errorf("save point %s has no parent %d", hash, n)
+	}
+	return commit.Parents[n-1], nil
+}
+
+// nthAncestor follows first parents n times
+func nthAncestor(hash string, n int) (string, error) {
+	for i := 0; i < n; i++ {
+		parent, err := nthParent(hash, 1)
+		if err != nil {
+			return "", err
+		}
+		hash = parent
+	}
+	return hash, nil
+}
+
+// savePointAtDate returns the newest save point on the first-parent
+// history of hash that was made at or before the date
+func savePointAtDate(hash, date string) (string, error) {
+	when, err := parseDate(date)
+	if err != nil {
+		return "", err
+	}
+
+	for hash != "" {
+		commit, err := readCommit(hash)
+		if err != nil {
+			return "", err
+		}
+		if saved, err := time.Parse(time.RFC3339, commit.Timestamp); err == nil && !saved.After(when) {
+			return hash, nil
+		}
+		hash = commit.Parent
+	}
+	return "", fmt.Errorf("no save point as old as %s", date)
+}
+
+// parseDate understands absolute dates such as 2024-05-01 or
+// 2024-05-01 14:30:00, RFC 3339 timestamps, and relative ones such as
+// "now", "yesterday" and "3 days ago"
+func parseDate(date string) (time.Time, error) {
+	date = strings.TrimSpace(strings.ToLower(date))
+	now := time.Now()
+
+	switch date {
+	case "now":
+		return now, nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	}
+
+	if match := relativeDate.FindStringSubmatch(date); match != nil {
+		n, err := strconv.Atoi(match[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", date)
+		}
+		switch match[2] {
+		case "second":
+			return now.Add(-time.Duration(n) * time.Second), nil
+		case "minute":
+			return now.Add(-time.Duration(n) * time.Minute), nil
+		case "hour":
+			return now.Add(-time.Duration(n) * time.Hour), nil
+		case "day":
+			return now.AddDate(0, 0, -n), nil
+		case "week":
+			return now.AddDate(0, 0, -7*n), nil
+		case "month":
+			return now.AddDate(0, -n, 0), nil
+		default:
+			return now.AddDate(-n, 0, 0), nil
+		}
+	}
+
+	if when, err := time.Parse(time.RFC3339, strings.ToUpper(date)); err == nil {
+		return when, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02t15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if when, err := time.ParseInLocation(layout, date, time.Local); err == nil {
+			if layout == "2006-01-02" {
+				// A bare day means the end of that day
+				when = when.AddDate(0, 0, 1).Add(-time.Second)
+			}
+			return when, nil
+		}
+	}
+	if _, err := strconv.Atoi(date); err == nil {
+		return time.Time{}, fmt.Errorf("@{%s} needs a date; numbered history entries are not recorded", date)
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", date)
+}
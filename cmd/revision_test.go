@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"microgit/utils"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveRevision(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	// Save points are written directly so their timestamps can be chosen
+	save := func(message string, timestamp time.Time, parents ...string) string {
+		content, _ := json.Marshal(message)
+		blob, err := utils.WriteObject(utils.BLOB_OBJECT, content)
+		if err != nil {
+			t.Fatalf("WriteObject failed: %v", err)
+		}
+		hash, err := writeSavePointObject(utils.SavePoint{
+			Message:   message,
+			Timestamp: timestamp.Format(time.RFC3339),
+			Parents:   parents,
+			Files:     map[string]string{"f.txt": blob},
+		})
+		if err != nil {
+			t.Fatalf("writeSavePointObject failed: %v", err)
+		}
+		return hash
+	}
+
+	now := time.Now()
+	first := save("first", now.AddDate(0, 0, -10))
+	second := save("second", now.AddDate(0, 0, -5), first)
+	side := save("side", now.AddDate(0, 0, -4), first)
+	merge := save("merge", now.AddDate(0, 0, -1), second, side)
+	if err := setHead(merge); err != nil {
+		t.Fatalf("setHead failed: %v", err)
+	}
+	updateRef("refs/tags/v1", second)
+
+	tests := []struct {
+		rev, want string
+	}{
+		{"HEAD", merge},
+		{"@", merge},
+		{DEFAULT_BRANCH, merge},
+		{"refs/heads/" + DEFAULT_BRANCH, merge},
+		{"v1", second},
+		{"tags/v1", second},
+		{merge, merge},
+		{merge[:12], merge},
+		{"HEAD^", second},
+		{"HEAD^1", second},
+		{"HEAD^2", side},
+		{"HEAD^0", merge},
+		{"HEAD~", second},
+		{"HEAD~2", first},
+		{"HEAD^2~1", first},
+		{"HEAD^^", first},
+		{"v1~1", first},
+		{"HEAD@{now}", merge},
+		{"@{3 days ago}", second},
+		{DEFAULT_BRANCH + "@{1 week ago}~0", first},
+		{"main@{" + now.AddDate(0, 0, -2).Format("2006-01-02 15:04:05") + "}", second},
+	}
+	for _, test := range tests {
+		got, err := resolveRevision(test.rev)
+		if err != nil {
+			t.Errorf("resolveRevision(%q) failed: %v", test.rev, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("resolveRevision(%q) = %s, want %s", test.rev, got, test.want)
+		}
+	}
+
+	for _, rev := range []string{"", "nope", "HEAD~3", "HEAD^3", "HEAD@{1 month ago}", "HEAD@{1}", "HEAD@{now", "HEAD~@}", "HEAD^@x}", "abc"} {
+		if hash, err := resolveRevision(rev); err == nil {
+			t.Errorf("Expected resolveRevision(%q) to fail, got %s", rev, hash)
+		}
+	}
+
+	t.Run("ambiguous prefixes are reported", func(t *testing.T) {
+		// Write blobs until two of them share a prefix
+		seen := make(map[string]string)
+		prefix := ""
+		for i := 0; prefix == ""; i++ {
+			hash, err := utils.WriteObject(utils.BLOB_OBJECT, []byte(fmt.Sprintf("blob %d", i)))
+			if err != nil {
+				t.Fatalf("WriteObject failed: %v", err)
+			}
+			if _, ok := seen[hash[:MIN_PREFIX_LENGTH]]; ok {
+				prefix = hash[:MIN_PREFIX_LENGTH]
+			}
+			seen[hash[:MIN_PREFIX_LENGTH]] = hash
+		}
+
+		_, err := resolveRevision(prefix)
+		if err == nil || !strings.Contains(err.Error(), "ambiguous") {
+			t.Errorf("Expected an ambiguity error for %s, got %v", prefix, err)
+		}
+	})
+}
+
+func TestParseDate(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		date string
+		want time.Time
+	}{
+		{"2 hours ago", now.Add(-2 * time.Hour)},
+		{"3.days.ago", now.AddDate(0, 0, -3)},
+		{"1 week ago", now.AddDate(0, 0, -7)},
+		{"yesterday", now.AddDate(0, 0, -1)},
+		{"2024-05-01T10:00:00Z", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+		{"2024-05-01", time.Date(2024, 5, 1, 23, 59, 59, 0, time.Local)},
+	}
+	for _, test := range tests {
+		got, err := parseDate(test.date)
+		if err != nil {
+			t.Errorf("parseDate(%q) failed: %v", test.date, err)
+			continue
+		}
+		if diff := got.Sub(test.want); diff < -time.Minute || diff > time.Minute {
+			t.Errorf("parseDate(%q) = %v, want %v", test.date, got, test.want)
+		}
+	}
+
+	if _, err := parseDate("someday"); err == nil {
+		t.Errorf("Expected an invalid date to fail")
+	}
+}
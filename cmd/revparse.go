@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var revParseShort int
+
+// revParseCmd represents the rev-parse command
+var revParseCmd = &cobra.Command{
+	Use:   "rev-parse <rev>...",
+	Short: "Print the save point hash a revision names",
+	Long: `Resolve each revision to a save point hash and print it on its own line.
+
+A revision is a name optionally followed by suffixes:
+  HEAD, @                 the current save point
+  latest                  the newest save point of the current branch
+  <branch>, <tag>         a branch or tag name, or a full ref such as refs/heads/main
+  <hash>                  a full hash, or a unique prefix of at least 4 characters
+  <rev>~<n>               the n-th first-parent ancestor, e.g. HEAD~3 (<rev>~ is <rev>~1)
+  <rev>^<n>               the n-th parent, e.g. HEAD^2 for a merged branch (<rev>^ is <rev>^1)
+  <rev>@{<date>}          where <rev> was on a date, e.g. main@{yesterday},
+                          main@{2 weeks ago} or @{2024-05-01}
+
+The command exits with a non-zero status if any revision cannot be resolved.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			fmt.Println("Error: No revision specified")
+			os.Exit(1)
+		}
+
+		failed := false
+		for _, rev := range args {
+			hash, err := resolveRevision(rev)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				failed = true
+				continue
+			}
+			if revParseShort > 0 && revParseShort < len(hash) {
+				hash = hash[:max(revParseShort, MIN_PREFIX_LENGTH)]
+			}
+			fmt.Println(hash)
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(revParseCmd)
+
+	revParseCmd.Flags().IntVar(&revParseShort, "short", 0, "Abbreviate hashes to this many characters")
+}
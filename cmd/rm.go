@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rmCached bool
+	rmForce  bool
+)
+
+// workingHash returns the blob hash of a file in the working tree, or "" if
+// it does not exist
+func workingHash(file, tracked string) string {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return blobHash(content, tracked)
+}
+
+// removeFiles stops tracking every index entry selected by specs and, unless
+// cached is set, deletes the files too. Unless force is set, files whose
+// unsaved changes would be lost are refused.
+func removeFiles(specs []string, cached, force bool) ([]string, error) {
+	index, err := readIndex()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	committed := getCommittedFiles()
+
+	selected := make(map[string]string)
+	for _, spec := range specs {
+		if _, err := path.Match(spec, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", spec, err)
+		}
+
+		matched := false
+		for file, hash := range index {
+			if matchPathspec(file, spec) {
+				selected[file] = hash
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("%s did not match any tracked file", spec)
+		}
+	}
+
+	if !force {
+		var unsafe []string
+		for file, hash := range selected {
+			working := workingHash(file, hash)
+			staged := hash != committed[file]
+			modified := working != "" && working != hash
+			if cached {
+				// Only the index copy is lost, so it must exist elsewhere
+				if staged && modified {
+					unsafe = append(unsafe, file)
+				}
+			} else if staged || modified {
+				unsafe = append(unsafe, file)
+			}
+		}
+		if len(unsafe) > 0 {
+			sort.Strings(unsafe)
+			return nil, fmt.Errorf("%s has unsaved changes; use --cached to keep the file or --force to remove it anyway", strings.Join(unsafe, ", "))
+		}
+	}
+
+	for file := range selected {
+		delete(index, file)
+	}
+	if err := writeIndex(index); err != nil {
+		return nil, fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := resolveConflicts(sortedKeys(selected)...); err != nil {
+		return nil, err
+	}
+
+	if !cached {
+		if err := updateWorkingTree(selected, map[string]string{}); err != nil {
+			return nil, err
+		}
+	}
+	return sortedKeys(selected), nil
+}
+
+// rmCmd represents the rm command
+var rmCmd = &cobra.Command{
+	Use:   "rm [--cached] [-f] <paths...>",
+	Short: "Remove files from the working tree and the index",
+	Long: `Stop tracking files and delete them, so the next save point no longer
+contains them.
+
+Usage:
+  microgit rm <paths...>           - Delete the files and stop tracking them
+  microgit rm --cached <paths...>  - Stop tracking the files but keep them on disk
+  microgit rm -f <paths...>        - Remove the files even if they have unsaved changes
+
+Paths may name files, directories or glob patterns such as "*.txt". Files
+whose unsaved changes would be lost are refused unless --force is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			fmt.Println("Error: No files specified")
+			return
+		}
+
+		removed, err := removeFiles(args, rmCached, rmForce)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		for _, file := range removed {
+			fmt.Printf("rm '%s'\n", file)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rmCmd)
+
+	rmCmd.Flags().BoolVar(&rmCached, "cached", false, "Only remove the files from the index")
+	rmCmd.Flags().BoolVarP(&rmForce, "force", "f", false, "Remove files even if they have unsaved changes")
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRmCmd(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	defer func() { rmCached, rmForce, addAll = false, false, false }()
+
+	os.MkdirAll("docs", 0755)
+	os.WriteFile("a.txt", []byte("a\n"), 0644)
+	os.WriteFile("b.txt", []byte("b\n"), 0644)
+	os.WriteFile("c.txt", []byte("c\n"), 0644)
+	os.WriteFile(filepath.Join("docs", "guide.txt"), []byte("guide\n"), 0644)
+	addCmd.Run(nil, []string{"."})
+	saveCmd.Run(nil, []string{"first"})
+
+	saved := func() map[string]string {
+		commit, err := readCommit(getHead())
+		if err != nil {
+			t.Fatalf("Failed to read HEAD: %v", err)
+		}
+		return commit.Files
+	}
+
+	t.Run("rm deletes the file and the next save omits it", func(t *testing.T) {
+		rmCmd.Run(nil, []string{"a.txt"})
+
+		if _, err := os.Stat("a.txt"); !os.IsNotExist(err) {
+			t.Error("Expected a.txt to be deleted")
+		}
+		saveCmd.Run(nil, []string{"remove a"})
+		if _, ok := saved()["a.txt"]; ok {
+			t.Error("Expected the save point to omit a.txt")
+		}
+	})
+
+	t.Run("rm refuses files with unsaved changes", func(t *testing.T) {
+		os.WriteFile("b.txt", []byte("edited\n"), 0644)
+		rmCmd.Run(nil, []string{"b.txt"})
+
+		if content, _ := os.ReadFile("b.txt"); string(content) != "edited\n" {
+			t.Error("Expected b.txt to be kept")
+		}
+		if index, _ := readIndex(); index["b.txt"] == "" {
+			t.Error("Expected b.txt to stay tracked")
+		}
+
+		rmForce = true
+		rmCmd.Run(nil, []string{"b.txt"})
+		rmForce = false
+		if _, err := os.Stat("b.txt"); !os.IsNotExist(err) {
+			t.Error("Expected --force to delete b.txt")
+		}
+		saveCmd.Run(nil, []string{"remove b"})
+	})
+
+	t.Run("rm --cached keeps the file but stops tracking it", func(t *testing.T) {
+		rmCached = true
+		rmCmd.Run(nil, []string{"c.txt"})
+		rmCached = false
+
+		if _, err := os.Stat("c.txt"); err != nil {
+			t.Error("Expected c.txt to be kept on disk")
+		}
+		if _, ok := readIndexEntries(t)["c.txt"]; ok {
+			t.Error("Expected c.txt to leave the index")
+		}
+		saveCmd.Run(nil, []string{"untrack c"})
+		if _, ok := saved()["c.txt"]; ok {
+			t.Error("Expected the save point to omit c.txt")
+		}
+	})
+
+	t.Run("add -A stages deleted files", func(t *testing.T) {
+		os.RemoveAll("docs")
+		addAll = true
+		addCmd.Run(nil, nil)
+		addAll = false
+
+		if _, ok := readIndexEntries(t)["docs/guide.txt"]; ok {
+			t.Error("Expected docs/guide.txt to leave the index")
+		}
+		if _, ok := readIndexEntries(t)["c.txt"]; !ok {
+			t.Error("Expected add -A to track c.txt again")
+		}
+		saveCmd.Run(nil, []string{"remove docs"})
+	})
+
+	t.Run("removing every file can be saved", func(t *testing.T) {
+		rmCmd.Run(nil, []string{"."})
+		saveCmd.Run(nil, []string{"empty"})
+
+		if files := saved(); len(files) != 0 {
+			t.Errorf("Expected an empty save point, got %v", files)
+		}
+	})
+
+	t.Run("unknown paths are an error", func(t *testing.T) {
+		if _, err := removeFiles([]string{"missing.txt"}, false, false); err == nil {
+			t.Error("Expected an error for a path that is not tracked")
+		}
+	})
+}
+
+// readIndexEntries reads the index or fails the test
+func readIndexEntries(t *testing.T) map[string]string {
+	t.Helper()
+	index, err := readIndex()
+	if err != nil {
+		t.Fatalf("Failed to read index: %v", err)
+	}
+	return index
+}
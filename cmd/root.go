@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"microgit/utils"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -13,28 +14,53 @@ var rootCmd = &cobra.Command{
 	Short: "MicroGit - A simple version control system",
 	Long: `MicroGit is a simple version control system that provides basic Git-like functionality.
 It allows you to track changes in your files and manage versions of your project.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		upgradeRepository()
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Welcome to MicroGit! Use --help to see available commands.")
 	},
 }
 
+// upgradeRepository performs the automatic one-time upgrades an existing
+// repository needs before any command touches it
+func upgradeRepository() {
+	if _, err := os.Stat(utils.DEFAULT_PATH); err != nil {
+		return
+	}
+
+	// Move objects from the single flat directory into fan-out directories
+	if utils.ReadFormat().Layout != utils.LAYOUT_SHARDED {
+		moved, err := utils.ShardObjects()
+		if err != nil {
+			fmt.Printf("Error upgrading object storage: %v\n", err)
+			return
+		}
+		if moved > 0 {
+			fmt.Printf("Moved %d object(s) into fan-out directories\n", moved)
+		}
+	}
+
+	// Replace the HEAD and LATEST pair with a default branch
+	if err := migrateRefs(); err != nil {
+		fmt.Printf("Error upgrading references: %v\n", err)
+		return
+	}
+
+	// Make the index list every tracked file, not just the staged ones, in
+	// the binary format
+	if err := upgradeIndex(); err != nil {
+		fmt.Printf("Error upgrading the index: %v\n", err)
+	}
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	rootCmd.SetArgs(expandAlias(os.Args[1:]))
+
 	err := rootCmd.Execute()
 	if err != nil {
 		os.Exit(1)
 	}
 }
-
-func init() {
-	// Here you will define your flags and configuration settings.
-	// Cobra supports persistent flags, which, if defined here,
-	// will be global for your application.
-
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.microgit.yaml)")
-
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-}
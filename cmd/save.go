@@ -12,90 +12,124 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// getHead returns the hash of the save point HEAD resolves to, or "" when
+// nothing has been saved on the current branch yet
 func getHead() string {
-	headPath := filepath.Join(utils.DEFAULT_PATH, "HEAD")
-
-	data, err := os.ReadFile(headPath)
-	if err != nil {
-		return ""
+	if branch := currentBranch(); branch != "" {
+		return readRef(branchRef(branch))
 	}
-	return strings.TrimSpace(string(data))
+	return readHeadFile()
 }
 
+// setHead records hash as the new HEAD: the current branch moves to it, or
+// HEAD itself when it is detached
 func setHead(hash string) error {
-	headPath := filepath.Join(utils.DEFAULT_PATH, "HEAD")
-	latestPath := filepath.Join(utils.DEFAULT_PATH, "LATEST")
-
-	errChan := make(chan error, 2) // Buffer for 2 potential errors
-
-	// Write HEAD file asynchronously
-	go func() {
-		err := os.WriteFile(headPath, []byte(hash), 0644)
-		errChan <- err
-	}()
-
-	// Write LATEST file asynchronously
-	go func() {
-		err := os.WriteFile(latestPath, []byte(hash), 0644)
-		errChan <- err
-	}()
-
-	// Wait for both operations to complete and check for errors
-	for i := 0; i < 2; i++ {
-		if err := <-errChan; err != nil {
+	if branch := currentBranch(); branch != "" {
+		if err := updateRef(branchRef(branch), hash); err != nil {
 			return fmt.Errorf("failed to write reference file: %w", err)
 		}
+		return nil
 	}
 
+	headPath := filepath.Join(utils.DEFAULT_PATH, "HEAD")
+	if err := os.WriteFile(headPath, []byte(hash), 0644); err != nil {
+		return fmt.Errorf("failed to write reference file: %w", err)
+	}
 	return nil
 }
 
+// readIndex returns the path -> hash entries of every tracked file
 func readIndex() (map[string]string, error) {
-	index := make(map[string]string)
+	return utils.ReadIndex()
+}
 
-	indexPath := filepath.Join(utils.DEFAULT_PATH, "index")
-	data, err := os.ReadFile(indexPath)
-	if err != nil {
-		return index, err
+// writeIndex replaces the whole index with the given path -> hash entries
+func writeIndex(index map[string]string) error {
+	return utils.WriteIndex(index)
+}
+
+// upgradeIndex brings an older index up to date: one that lists only staged
+// files is filled in with the rest of the files tracked at HEAD, including
+// those older save points carried without listing them, and a
+// text index is rewritten in the binary format
+func upgradeIndex() error {
+	format := utils.ReadFormat()
+	if format.Index == utils.INDEX_BINARY && utils.IsBinaryIndex() {
+		return nil
 	}
 
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		if line == "" {
-			continue
+	index, err := readIndex()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	files := index
+	if format.Index == utils.INDEX_STAGED {
+		files = make(map[string]string)
+
+		// The index only lists what was staged since HEAD, so it cannot be
+		// completed, and must be kept as it is, while HEAD is unreadable
+		if head := getHead(); head != "" {
+			tracked, err := fullSnapshot(head)
+			if err != nil {
+				return fmt.Errorf("cannot fill in the index from HEAD: %w", err)
+			}
+			for path, hash := range tracked {
+				files[path] = hash
+			}
 		}
-		parts := strings.SplitN(line, " ", 2)
-		if len(parts) == 2 {
-			index[parts[0]] = parts[1]
+		for path, hash := range index {
+			files[path] = hash
 		}
 	}
-	return index, nil
+	if err := writeIndex(files); err != nil {
+		return err
+	}
+
+	format.Index = utils.INDEX_BINARY
+	return utils.WriteFormat(format)
 }
 
 func writeSavePointObject(savePoint utils.SavePoint) (string, error) {
-	jsonData, err := json.MarshalIndent(savePoint, "", "  ")
+	// Record who made the save point unless the caller already has
+	signSavePoint(&savePoint)
+	savePoint.Complete = true
+	return storeSavePoint(savePoint)
+}
+
+// storeSavePoint writes a save point exactly as given, without filling in
+// an identity
+func storeSavePoint(savePoint utils.SavePoint) (string, error) {
+	// Store the snapshot as a tree so unchanged directories are shared
+	treeHash, err := utils.WriteTree(savePoint.Files)
 	if err != nil {
 		return "", err
 	}
+	savePoint.Tree = treeHash
+	savePoint.Files = nil
 
-	// Hash of the entire SavePoint JSON
-	hash := utils.HashContent(jsonData)
+	// Parents supersedes the single Parent field
+	savePoint.Parents = savePoint.ParentHashes()
+	savePoint.Parent = ""
 
-	err = utils.WriteObject(hash, jsonData)
+	jsonData, err := json.MarshalIndent(savePoint, "", "  ")
 	if err != nil {
 		return "", err
 	}
 
-	return hash, nil
+	// Hash of the entire SavePoint JSON and its object header
+	return utils.WriteObject(utils.SAVEPOINT_OBJECT, jsonData)
 }
 
 // saveCmd represents the save command
 var saveCmd = &cobra.Command{
 	Use:   "save",
-	Short: "Save the current state of staged files",
-	Long: `Save the current state of all staged files as a new commit.
+	Short: "Save the current state of tracked files",
+	Long: `Save a snapshot of every tracked file, as staged in the index, as a new commit.
 This command requires a commit message that describes the changes being saved.
-The staged files will be committed and the staging area will be cleared after the save.`,
+Files that were not staged again since the last save are carried over
+unchanged, and the staging area keeps tracking them for the next save.
+Files removed with "microgit rm" or "microgit add -A" are left out.
+Nothing is saved if the snapshot is the same as the last one.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) < 1 {
 			fmt.Println("Usage: microgit save \"message\"")
@@ -110,21 +144,36 @@ The staged files will be committed and the staging area will be cleared after th
 			return
 		}
 
-		if len(index) == 0 {
+		// The index holds every tracked file, so it is the whole snapshot.
+		// An empty one is only meaningful once it records deletions.
+		parent := getHead()
+		if len(index) == 0 && parent == "" {
 			fmt.Println("No files have been added")
 			return
 		}
 
-		parent := getHead()
-		timestamp := time.Now().Format(time.RFC3339)
+		mergeHead := readMergeHead()
+		if conflicts := readMergeConflicts(); mergeHead != "" && len(conflicts) > 0 {
+			fmt.Printf("Error: %s still has merge conflicts; fix them and add the files first\n", strings.Join(conflicts, ", "))
+			return
+		}
+		if parent != "" && mergeHead == "" && sameFiles(index, getCommittedFiles()) {
+			fmt.Println("Nothing to save: no changes since the last save point")
+			return
+		}
 
 		savePoint := utils.SavePoint{
 			Message:   message,
-			Timestamp: timestamp,
+			Timestamp: time.Now().Format(time.RFC3339),
 			Parent:    parent,
 			Files:     index,
 		}
 
+		// Concluding a merge records both lines of history
+		if mergeHead != "" {
+			savePoint.Parents = []string{parent, mergeHead}
+		}
+
 		hash, err := writeSavePointObject(savePoint)
 		if err != nil {
 			fmt.Println("failed to write commit: %w", err)
@@ -138,11 +187,12 @@ The staged files will be committed and the staging area will be cleared after th
 		}
 
 		fmt.Printf("Saved: %s\n", hash)
+		clearMergeState()
 
-		// Clear the staging area
-
-		indexPath := filepath.Join(utils.DEFAULT_PATH, "index")
-		os.WriteFile(indexPath, []byte(""), 0644)
+		if currentBranch() == "" {
+			fmt.Println("Warning: HEAD is detached, so this save point is not on any branch.")
+			fmt.Printf("To keep it, create a branch: microgit branch <name> %s\n", hash)
+		}
 	},
 }
 
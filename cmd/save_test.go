@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"encoding/json"
+	"microgit/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveWritesTrees(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	if err := os.MkdirAll("dir/sub", 0755); err != nil {
+		t.Fatalf("Failed to create directories: %v", err)
+	}
+	files := map[string]string{
+		"a.txt":         "first",
+		"dir/b.txt":     "second",
+		"dir/sub/c.txt": "third",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile failed %v", err)
+		}
+	}
+
+	addCmd.Run(nil, []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"})
+	saveCmd.Run(nil, []string{"first save"})
+	first := getHead()
+
+	t.Run("save point stores a root tree", func(t *testing.T) {
+		kind, data, err := utils.ReadObject(first)
+		if err != nil {
+			t.Fatalf("Failed to read save point: %v", err)
+		}
+		if kind != utils.SAVEPOINT_OBJECT {
+			t.Errorf("Expected a savepoint object, got %s", kind)
+		}
+
+		var raw map[string]interface{}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			t.Fatalf("Failed to parse save point: %v", err)
+		}
+		if _, ok := raw["files"]; ok {
+			t.Errorf("Expected save point not to store a flat files map")
+		}
+		if raw["tree"] == "" || raw["tree"] == nil {
+			t.Errorf("Expected save point to reference a root tree")
+		}
+	})
+
+	t.Run("readCommit flattens the tree", func(t *testing.T) {
+		commit, err := readCommit(first)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(commit.Files) != len(files) {
+			t.Fatalf("Expected %d files, got %d", len(files), len(commit.Files))
+		}
+		for path, content := range files {
+			if commit.Files[path] != utils.HashObject(utils.BLOB_OBJECT, []byte(content)) {
+				t.Errorf("Unexpected hash for %s: %s", path, commit.Files[path])
+			}
+		}
+	})
+
+	t.Run("unchanged directories reuse their tree", func(t *testing.T) {
+		if err := os.WriteFile("a.txt", []byte("changed"), 0644); err != nil {
+			t.Fatalf("WriteFile failed %v", err)
+		}
+		addCmd.Run(nil, []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"})
+		saveCmd.Run(nil, []string{"second save"})
+
+		dirHash := func(hash string) string {
+			commit, err := readCommit(hash)
+			if err != nil {
+				t.Fatalf("Failed to read commit: %v", err)
+			}
+			tree, err := utils.ReadTree(commit.Tree)
+			if err != nil {
+				t.Fatalf("Failed to read tree: %v", err)
+			}
+			for _, entry := range tree.Entries {
+				if entry.Name == "dir" {
+					return entry.Hash
+				}
+			}
+			t.Fatalf("Expected a dir entry in the root tree")
+			return ""
+		}
+
+		if dirHash(first) != dirHash(getHead()) {
+			t.Errorf("Expected unchanged directory to keep the same tree hash")
+		}
+	})
+}
+
+func TestSaveRecordsFullSnapshots(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	os.WriteFile("a.txt", []byte("a"), 0644)
+	os.WriteFile("b.txt", []byte("b"), 0644)
+	addCmd.Run(nil, []string{"a.txt", "b.txt"})
+	saveCmd.Run(nil, []string{"first"})
+	first := getHead()
+
+	t.Run("unstaged files are carried forward", func(t *testing.T) {
+		os.WriteFile("a.txt", []byte("changed"), 0644)
+		addCmd.Run(nil, []string{"a.txt"})
+		saveCmd.Run(nil, []string{"second"})
+
+		commit, err := readCommit(getHead())
+		if err != nil {
+			t.Fatalf("readCommit failed: %v", err)
+		}
+		if len(commit.Files) != 2 || commit.Files["b.txt"] != utils.HashObject(utils.BLOB_OBJECT, []byte("b")) {
+			t.Errorf("Expected b.txt to be carried forward, got %v", commit.Files)
+		}
+
+		index, _ := readIndex()
+		if !sameFiles(index, commit.Files) {
+			t.Errorf("Expected the index to keep tracking every saved file, got %v", index)
+		}
+	})
+
+	t.Run("saving without changes does nothing", func(t *testing.T) {
+		head := getHead()
+		saveCmd.Run(nil, []string{"nothing new"})
+		if getHead() != head {
+			t.Errorf("Expected no save point when nothing changed")
+		}
+	})
+
+	t.Run("staged-only indexes are upgraded", func(t *testing.T) {
+		resetCmd.Run(nil, []string{first})
+		os.WriteFile(filepath.Join(utils.DEFAULT_PATH, "index"), []byte("b.txt "+utils.HashObject(utils.BLOB_OBJECT, []byte("new b"))), 0644)
+		format := utils.CurrentFormat()
+		format.Index = utils.INDEX_STAGED
+		utils.WriteFormat(format)
+
+		if err := upgradeIndex(); err != nil {
+			t.Fatalf("upgradeIndex failed: %v", err)
+		}
+
+		index, _ := readIndex()
+		commit, _ := readCommit(first)
+		if len(index) != 2 || index["a.txt"] != commit.Files["a.txt"] || index["b.txt"] != utils.HashObject(utils.BLOB_OBJECT, []byte("new b")) {
+			t.Errorf("Expected HEAD with the staged file laid over it, got %v", index)
+		}
+		if utils.ReadFormat().Index != utils.INDEX_BINARY || !utils.IsBinaryIndex() {
+			t.Errorf("Expected the index to be rewritten in the binary format")
+		}
+	})
+
+	t.Run("staged-only indexes are kept while HEAD is unreadable", func(t *testing.T) {
+		headPath := filepath.Join(utils.DEFAULT_PATH, "HEAD")
+		original, _ := os.ReadFile(headPath)
+		defer os.WriteFile(headPath, original, 0644)
+		os.WriteFile(headPath, []byte(strings.Repeat("ab", 32)), 0644)
+
+		staged := []byte("b.txt " + utils.HashObject(utils.BLOB_OBJECT, []byte("new b")))
+		os.WriteFile(utils.IndexPath(), staged, 0644)
+		format := utils.CurrentFormat()
+		format.Index = utils.INDEX_STAGED
+		utils.WriteFormat(format)
+
+		if err := upgradeIndex(); err == nil {
+			t.Error("Expected upgradeIndex to fail")
+		}
+		if data, _ := os.ReadFile(utils.IndexPath()); string(data) != string(staged) {
+			t.Errorf("Expected the index to be left alone, got %q", data)
+		}
+		if utils.ReadFormat().Index != utils.INDEX_STAGED {
+			t.Errorf("Expected the index format to stay %s", utils.INDEX_STAGED)
+		}
+	})
+
+	t.Run("text indexes are rewritten in the binary format", func(t *testing.T) {
+		spaced := utils.HashObject(utils.BLOB_OBJECT, []byte("spaced"))
+		os.WriteFile(utils.IndexPath(), []byte("my notes.txt "+spaced+"\nb.txt "+spaced), 0644)
+		format := utils.CurrentFormat()
+		format.Index = utils.INDEX_FULL
+		utils.WriteFormat(format)
+
+		if err := upgradeIndex(); err != nil {
+			t.Fatalf("upgradeIndex failed: %v", err)
+		}
+
+		index, err := readIndex()
+		if err != nil {
+			t.Fatalf("readIndex failed: %v", err)
+		}
+		if len(index) != 2 || index["my notes.txt"] != spaced || index["b.txt"] != spaced {
+			t.Errorf("Expected the text entries to be kept, got %v", index)
+		}
+		if utils.ReadFormat().Index != utils.INDEX_BINARY || !utils.IsBinaryIndex() {
+			t.Errorf("Expected the index to be rewritten in the binary format")
+		}
+	})
+}
+
+func TestIndexFormat(t *testing.T) {
+	entries := map[string]string{
+		"b.txt":         utils.HashObject(utils.BLOB_OBJECT, []byte("b")),
+		"a dir/a.txt":   utils.HashObject(utils.BLOB_OBJECT, []byte("a")),
+		"line\nbreak":   utils.HashObject(utils.BLOB_OBJECT, []byte("odd")),
+		"unicode/é.txt": utils.HashObject(utils.BLOB_OBJECT, []byte("é")),
+	}
+
+	data, err := utils.EncodeIndex(entries)
+	if err != nil {
+		t.Fatalf("EncodeIndex failed: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "MGIN") {
+		t.Errorf("Expected the index to start with its magic, got %q", data[:4])
+	}
+	if first := strings.Index(string(data), "a dir/a.txt"); first < 0 || first > strings.Index(string(data), "b.txt") {
+		t.Errorf("Expected entries sorted by path")
+	}
+
+	decoded, err := utils.DecodeIndex(data)
+	if err != nil {
+		t.Fatalf("DecodeIndex failed: %v", err)
+	}
+	if !sameFiles(decoded, entries) {
+		t.Errorf("Expected %v back, got %v", entries, decoded)
+	}
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[20] ^= 0xff
+	if _, err := utils.DecodeIndex(corrupt); err == nil {
+		t.Error("Expected a checksum mismatch for a corrupted index")
+	}
+	if _, err := utils.DecodeIndex(data[:len(data)-1]); err == nil {
+		t.Error("Expected an error for a truncated index")
+	}
+
+	if _, err := utils.EncodeIndex(map[string]string{"a.txt": "not a hash"}); err == nil {
+		t.Error("Expected an error for an invalid hash")
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"io/fs"
 	"microgit/utils"
 	"os"
 	"path/filepath"
@@ -15,25 +16,31 @@ type fileResult struct {
 	err  error
 }
 
+// blobHash returns the blob hash of content. Repositories that have not
+// been migrated still track blobs written before type headers existed,
+// which are named by the hash of the bare content, so tracked is returned
+// when it names the same content that way.
+func blobHash(content []byte, tracked string) string {
+	hash := utils.HashObject(utils.BLOB_OBJECT, content)
+	if tracked != "" && tracked != hash && tracked == utils.HashContent(content) {
+		return tracked
+	}
+	return hash
+}
+
+// getWorkingFiles hashes every file of the working tree that is tracked or
+// not ignored
 func getWorkingFiles() (map[string]string, error) {
 	files := make(map[string]string)
+	index, _ := readIndex()
 
-	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip internal directory
-		if strings.HasPrefix(path, utils.DEFAULT_PATH) || strings.HasPrefix(path, ".git/") || info.IsDir() {
-			return nil
-		}
-
+	err := walkWorkingTree(func(path string) error {
 		content, err := os.ReadFile(path)
 		if err != nil {
 			return nil
 		}
 
-		files[path] = utils.HashContent(content)
+		files[path] = blobHash(content, index[path])
 		return nil
 	})
 
@@ -46,11 +53,11 @@ func getCommittedFiles() map[string]string {
 		return map[string]string{}
 	}
 
-	commit, err := readCommit(head)
+	files, err := fullSnapshot(head)
 	if err != nil {
 		return map[string]string{}
 	}
-	return commit.Files
+	return files
 }
 
 func getStatusData() (map[string]string, map[string]string, map[string]string, error) {
@@ -95,6 +102,91 @@ func getStatusData() (map[string]string, map[string]string, map[string]string, e
 		nil
 }
 
+// uncommittedChanges returns the sorted tracked paths whose staged or
+// working copy differs from the HEAD save point
+func uncommittedChanges() ([]string, error) {
+	index, committed, working, err := getStatusData()
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]bool)
+	for path, hash := range index {
+		if committed[path] != hash {
+			changed[path] = true
+		}
+	}
+	for path, hash := range committed {
+		if index[path] != hash || working[path] != hash {
+			changed[path] = true
+		}
+	}
+
+	return sortedKeys(changed), nil
+}
+
+// checkClean refuses to go on when moving the working tree from ours to
+// theirs could lose local changes: edits to tracked files, or untracked
+// files that theirs would overwrite, including those in a directory that
+// becomes a file
+func checkClean(theirs map[string]string, ours map[string]string) error {
+	changes, err := uncommittedChanges()
+	if err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		return fmt.Errorf("you have unsaved changes in %s; save or discard them first", strings.Join(changes, ", "))
+	}
+
+	for path := range theirs {
+		if _, tracked := ours[path]; tracked {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("untracked file %s would be overwritten", path)
+		}
+
+		// A directory of tracked files is removed to make room, so only
+		// untracked files inside it would be lost
+		err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			if _, tracked := ours[filepath.ToSlash(file)]; !tracked {
+				return fmt.Errorf("untracked file %s would be overwritten", filepath.ToSlash(file))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trackedWorkingFiles returns the working tree hash of every path that is
+// saved at HEAD or staged, leaving out tracked files missing from disk
+func trackedWorkingFiles() (map[string]string, error) {
+	index, committed, working, err := getStatusData()
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := make(map[string]string)
+	for _, files := range []map[string]string{committed, index} {
+		for path := range files {
+			if hash, ok := working[path]; ok {
+				tracked[path] = hash
+			}
+		}
+	}
+	return tracked, nil
+}
+
 // statusCmd represents the status command
 var statusCmd = &cobra.Command{
 	Use:   "status",
@@ -102,7 +194,8 @@ var statusCmd = &cobra.Command{
 	Long: `Display the state of the working directory and the staging area.
 Shows which files have been staged for the next commit and which files
 are untracked. This helps you understand what will be included in your
-next commit.`,
+next commit. Staged renames are shown as "old -> new", and a tracked file
+that was moved without telling MicroGit is matched with its new name.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		index, committed, working, err := getStatusData()
 		if err != nil {
@@ -110,43 +203,86 @@ next commit.`,
 			return
 		}
 
+		if branch := currentBranch(); branch != "" {
+			fmt.Printf("On branch %s\n\n", branch)
+		} else {
+			fmt.Printf("HEAD detached at %s\n\n", getHead())
+		}
+
+		if mergeHead := readMergeHead(); mergeHead != "" {
+			fmt.Printf("You are merging %s; fix conflicts, add the files and run microgit save\n\n", mergeHead)
+		}
+
+		renames, err := detectRenames(diffSide{files: committed}, diffSide{files: index})
+		if err != nil {
+			fmt.Printf("Error detecting renames: %v\n", err)
+			return
+		}
+		renamed := make(map[string]bool)
+		for _, r := range renames {
+			renamed[r.oldPath], renamed[r.path] = true, true
+		}
+
 		fmt.Println("=== Staged ===")
-		for path, hash := range index {
-			if committed[path] != hash {
+		for _, path := range sortedKeys(index) {
+			if committed[path] != index[path] && !renamed[path] {
 				fmt.Println(path)
 			}
 		}
+		for _, r := range renames {
+			fmt.Printf("%s -> %s (renamed)\n", r.oldPath, r.path)
+		}
+		for _, path := range sortedKeys(committed) {
+			if _, ok := index[path]; !ok && !renamed[path] {
+				fmt.Println(path + " (deleted)")
+			}
+		}
 
 		fmt.Println("\n=== Modified but not Staged ===")
-		for path, hash := range working {
-			if indexHash, ok := index[path]; ok && indexHash != hash {
+		for _, path := range sortedKeys(working) {
+			if indexHash, ok := index[path]; ok && indexHash != working[path] {
 				fmt.Println(path)
 			}
 		}
 
 		fmt.Println("\n=== Untracked Files ===")
-		for path := range working {
-			_, inIndex := index[path]
-			_, inCommit := committed[path]
-			if !inIndex && !inCommit {
+		for _, path := range sortedKeys(working) {
+			if _, tracked := index[path]; !tracked {
 				fmt.Println(path)
 			}
 		}
 
-		fmt.Println("\n=== Deleted ===")
-		seen := map[string]bool{}
-
-		// Deleted files that were in the last commit
-		for path := range committed {
+		// Tracked files missing from disk; "add -A" or "rm" stages them. A
+		// file that only moved is matched with the untracked file it became.
+		gone, untracked := make(map[string]string), make(map[string]string)
+		for path, hash := range index {
 			if _, ok := working[path]; !ok {
-				fmt.Println(path + " (was saved)")
-				seen[path] = true
+				gone[path] = hash
 			}
 		}
+		for path, hash := range working {
+			if _, tracked := index[path]; !tracked {
+				untracked[path] = hash
+			}
+		}
+		moves, err := detectRenames(diffSide{files: gone}, diffSide{files: untracked, working: true})
+		if err != nil {
+			fmt.Printf("Error detecting renames: %v\n", err)
+			return
+		}
+		movedTo := make(map[string]string)
+		for _, r := range moves {
+			movedTo[r.oldPath] = r.path
+		}
 
-		// Deleted files that were staged
-		for path := range index {
-			if _, ok := working[path]; !ok && !seen[path] {
+		fmt.Println("\n=== Deleted ===")
+		for _, path := range sortedKeys(gone) {
+			switch _, saved := committed[path]; {
+			case movedTo[path] != "":
+				fmt.Printf("%s (moved to %s)\n", path, movedTo[path])
+			case saved:
+				fmt.Println(path + " (was saved)")
+			default:
 				fmt.Println(path + " (was staged)")
 			}
 		}
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"fmt"
+	"microgit/utils"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	tagAnnotate bool
+	tagMessage  string
+	tagDelete   bool
+	tagForce    bool
+	tagShow     bool
+)
+
+// peelTag follows annotated tags until it reaches the object they point at.
+// Any other object is returned as it is.
+func peelTag(hash string) (string, error) {
+	seen := make(map[string]bool)
+	for {
+		kind, _, err := utils.ReadObject(hash)
+		if err != nil {
+			return "", err
+		}
+		if kind != utils.TAG_OBJECT {
+			return hash, nil
+		}
+		if seen[hash] {
+			return "", fmt.Errorf("tag %s points back at itself", hash)
+		}
+		seen[hash] = true
+
+		tag, err := utils.ReadTag(hash)
+		if err != nil {
+			return "", err
+		}
+		hash = tag.Target
+	}
+}
+
+// tagger names the person creating an annotated tag
+func tagger() string {
+	return utils.FormatIdentity(identity("committer"))
+}
+
+// createTag points a new tag at the save point rev names. With annotate set
+// the ref points at a tag object carrying the message instead.
+func createTag(name, rev, message string, annotate, force bool) error {
+	if err := validRefName("tag", name); err != nil {
+		return err
+	}
+	if refExists(tagRef(name)) && !force {
+		return fmt.Errorf("tag '%s' already exists", name)
+	}
+
+	hash, err := resolveRevision(rev)
+	if err != nil {
+		return err
+	}
+	if _, err := readCommit(hash); err != nil {
+		return err
+	}
+
+	if annotate {
+		hash, err = utils.WriteTag(utils.Tag{
+			Name:      name,
+			Target:    hash,
+			Tagger:    tagger(),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Message:   message,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write tag: %w", err)
+		}
+	}
+
+	return updateRef(tagRef(name), hash)
+}
+
+func deleteTag(name string) (string, error) {
+	if err := validRefName("tag", name); err != nil {
+		return "", err
+	}
+	if !refExists(tagRef(name)) {
+		return "", fmt.Errorf("tag '%s' not found", name)
+	}
+
+	hash := readRef(tagRef(name))
+	return hash, deleteRef(tagRef(name))
+}
+
+// tagsBySavePoint returns the sorted names of the tags on each save point
+func tagsBySavePoint() map[string][]string {
+	tags := make(map[string][]string)
+
+	refs, _ := listRefs(tagRefPrefix)
+	for _, name := range sortedKeys(refs) {
+		if hash, err := peelTag(refs[name]); err == nil {
+			tags[hash] = append(tags[hash], name)
+		}
+	}
+	return tags
+}
+
+func showTag(name string) error {
+	if !refExists(tagRef(name)) {
+		return fmt.Errorf("tag '%s' not found", name)
+	}
+
+	hash := readRef(tagRef(name))
+	kind, _, err := utils.ReadObject(hash)
+	if err != nil {
+		return err
+	}
+	if kind == utils.TAG_OBJECT {
+		tag, err := utils.ReadTag(hash)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Tag: %s\n", tag.Name)
+		fmt.Printf("Tagger: %s\n", tag.Tagger)
+		fmt.Printf("Date: %s\n", tag.Timestamp)
+		fmt.Printf("Message: %s\n\n", tag.Message)
+	}
+
+	target, err := peelTag(hash)
+	if err != nil {
+		return err
+	}
+	commit, err := readCommit(target)
+	if err != nil {
+		return err
+	}
+	printSavePoint(target, commit, tagsBySavePoint()[target])
+	return nil
+}
+
+// tagCmd represents the tag command
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "List, create, delete or show tags",
+	Long: `Manage tags: fixed names for save points, such as releases.
+
+Usage:
+  microgit tag                           - List tags
+  microgit tag <name> [rev]              - Tag HEAD or the given revision
+  microgit tag -a -m <message> <name> [rev] - Create an annotated tag
+  microgit tag -d <name>...              - Delete tags
+  microgit tag --show <name>             - Show a tag and the save point it names
+
+A lightweight tag is just a name for a save point. An annotated tag also
+records who created it, when, and a message. Tags never move once created;
+use --force to replace one.
+
+Tags live in .microgit/refs/tags/<name> and can be used anywhere a revision
+is expected.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if tagDelete {
+			if len(args) == 0 {
+				fmt.Println("Error: No tag specified")
+				return
+			}
+			for _, name := range args {
+				hash, err := deleteTag(name)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					continue
+				}
+				fmt.Printf("Deleted tag '%s' (was %s)\n", name, hash)
+				warnOrphaned(hash)
+			}
+			return
+		}
+
+		if tagShow {
+			if len(args) != 1 {
+				fmt.Println("Usage: microgit tag --show <name>")
+				return
+			}
+			if err := showTag(args[0]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			return
+		}
+
+		if len(args) == 0 {
+			refs, err := listRefs(tagRefPrefix)
+			if err != nil {
+				fmt.Printf("Error listing tags: %v\n", err)
+				return
+			}
+			for _, name := range sortedKeys(refs) {
+				fmt.Println(name)
+			}
+			return
+		}
+
+		annotate := tagAnnotate || tagMessage != ""
+		if annotate && strings.TrimSpace(tagMessage) == "" {
+			fmt.Println("Error: annotated tags need a message; use -m <message>")
+			return
+		}
+
+		rev := "HEAD"
+		if len(args) > 1 {
+			rev = args[1]
+		}
+		previous := readRef(tagRef(args[0]))
+		if err := createTag(args[0], rev, tagMessage, annotate, tagForce); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Created tag '%s'\n", args[0])
+
+		// A replaced tag may have been all that kept its save points
+		if previous != readRef(tagRef(args[0])) {
+			warnOrphaned(previous)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tagCmd)
+
+	tagCmd.Flags().BoolVarP(&tagAnnotate, "annotate", "a", false, "Create an annotated tag")
+	tagCmd.Flags().StringVarP(&tagMessage, "message", "m", "", "Message for an annotated tag (implies -a)")
+	tagCmd.Flags().BoolVarP(&tagDelete, "delete", "d", false, "Delete tags")
+	tagCmd.Flags().BoolVarP(&tagForce, "force", "f", false, "Replace an existing tag")
+	tagCmd.Flags().BoolVar(&tagShow, "show", false, "Show a tag and the save point it names")
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"microgit/utils"
+	"os"
+	"testing"
+)
+
+func TestTags(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "microgit-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Change to the temporary directory
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	initCmd.Run(nil, nil)
+
+	defer func() { tagAnnotate, tagMessage, tagDelete, tagForce, tagShow = false, "", false, false, false }()
+
+	save := func(content, message string) string {
+		if err := os.WriteFile("temp.txt", []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile failed %v", err)
+		}
+		addCmd.Run(nil, []string{"temp.txt"})
+		saveCmd.Run(nil, []string{message})
+		return getHead()
+	}
+
+	first := save("one", "first")
+	second := save("two", "second")
+
+	t.Run("lightweight tags name a save point", func(t *testing.T) {
+		tagCmd.Run(nil, []string{"v1", first})
+
+		if readRef(tagRef("v1")) != first {
+			t.Errorf("Expected v1 to point at %s", first)
+		}
+		if hash, err := resolveRevision("v1"); err != nil || hash != first {
+			t.Errorf("resolveRevision(v1) = %s, %v", hash, err)
+		}
+	})
+
+	t.Run("annotated tags store a tag object", func(t *testing.T) {
+		tagMessage = "Second release"
+		tagCmd.Run(nil, []string{"v2"})
+		tagMessage = ""
+
+		tag, err := utils.ReadTag(readRef(tagRef("v2")))
+		if err != nil {
+			t.Fatalf("ReadTag failed: %v", err)
+		}
+		if tag.Name != "v2" || tag.Target != second || tag.Message != "Second release" || tag.Tagger == "" || tag.Timestamp == "" {
+			t.Errorf("Unexpected tag %+v", tag)
+		}
+		if hash, err := resolveRevision("v2~1"); err != nil || hash != first {
+			t.Errorf("resolveRevision(v2~1) = %s, %v", hash, err)
+		}
+	})
+
+	t.Run("tags do not move", func(t *testing.T) {
+		tagCmd.Run(nil, []string{"v1", second})
+		if readRef(tagRef("v1")) != first {
+			t.Errorf("Expected an existing tag to be left alone")
+		}
+
+		tagForce = true
+		tagCmd.Run(nil, []string{"v1", second})
+		if readRef(tagRef("v1")) != second {
+			t.Errorf("Expected --force to move the tag")
+		}
+		tagCmd.Run(nil, []string{"v1", first})
+		tagForce = false
+	})
+
+	t.Run("log decorations", func(t *testing.T) {
+		tagCmd.Run(nil, []string{"also-first", first})
+
+		tags := tagsBySavePoint()
+		if got := tags[first]; len(got) != 2 || got[0] != "also-first" || got[1] != "v1" {
+			t.Errorf("Expected %s to carry also-first and v1, got %v", first, got)
+		}
+		if got := tags[second]; len(got) != 1 || got[0] != "v2" {
+			t.Errorf("Expected %s to carry v2, got %v", second, got)
+		}
+	})
+
+	t.Run("tags keep objects alive", func(t *testing.T) {
+		checkoutCmd.Run(nil, []string{first})
+		detached := save("three", "detached work")
+		tagMessage = "Detached work"
+		tagCmd.Run(nil, []string{"kept", detached})
+		tagMessage = ""
+		checkoutCmd.Run(nil, []string{DEFAULT_BRANCH})
+
+		if orphaned := orphanedSavePoints(detached); len(orphaned) != 0 {
+			t.Errorf("Expected the tag to keep %s reachable, got %v", detached, orphaned)
+		}
+
+		reachable, err := reachableObjects()
+		if err != nil {
+			t.Fatalf("reachableObjects failed: %v", err)
+		}
+		if !reachable[readRef(tagRef("kept"))] || !reachable[detached] {
+			t.Errorf("Expected the tag object and its save point to be reachable")
+		}
+
+		report, err := runFsck()
+		if err != nil {
+			t.Fatalf("runFsck failed: %v", err)
+		}
+		if !report.ok() || len(report.dangling) != 0 {
+			t.Errorf("Expected a clean fsck, got corrupt=%v missing=%v dangling=%v", report.corrupt, report.missing, report.dangling)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		tagDelete = true
+		tagCmd.Run(nil, []string{"also-first"})
+		tagDelete = false
+
+		if refExists(tagRef("also-first")) {
+			t.Errorf("Expected also-first to be deleted")
+		}
+	})
+
+	t.Run("delete refuses names outside tags", func(t *testing.T) {
+		if _, err := deleteTag("../heads/" + DEFAULT_BRANCH); err == nil {
+			t.Error("Expected deleting ../heads/main to fail")
+		}
+		if !refExists(branchRef(DEFAULT_BRANCH)) {
+			t.Errorf("Expected branch %s to survive", DEFAULT_BRANCH)
+		}
+	})
+
+	t.Run("deleting the only tag orphans its save points", func(t *testing.T) {
+		annotated := readRef(tagRef("kept"))
+		detached, _ := peelTag(annotated)
+		if _, err := deleteTag("kept"); err != nil {
+			t.Fatalf("deleteTag failed: %v", err)
+		}
+
+		if orphaned := orphanedSavePoints(detached); len(orphaned) != 1 || orphaned[0] != detached {
+			t.Errorf("Expected %s to be left behind, got %v", detached, orphaned)
+		}
+	})
+}
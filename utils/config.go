@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigPath returns the repository's own configuration file
+func ConfigPath() string {
+	return filepath.Join(DEFAULT_PATH, "config")
+}
+
+// ReadConfigFile parses a configuration file of "key = value" lines into a
+// map. Keys are case-insensitive dotted names such as user.name; blank
+// lines and lines starting with # or ; are ignored. A missing file is empty.
+func ReadConfigFile(path string) (map[string]string, error) {
+	config := make(map[string]string)
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, number)
+		}
+		config[key] = strings.TrimSpace(value)
+	}
+	return config, scanner.Err()
+}
+
+// SetConfigValue sets key to value in the configuration file at path,
+// replacing an existing line for the key in place and keeping everything else
+func SetConfigValue(path, key, value string) error {
+	key = strings.ToLower(key)
+	lines, err := readConfigLines(path)
+	if err != nil {
+		return err
+	}
+
+	entry := key + " = " + value
+	var updated []string
+	found := false
+	for _, line := range lines {
+		if configLineKey(line) != key {
+			updated = append(updated, line)
+			continue
+		}
+		// Later duplicates of the key are dropped
+		if !found {
+			updated = append(updated, entry)
+			found = true
+		}
+	}
+	if !found {
+		updated = append(updated, entry)
+	}
+	return writeConfigLines(path, updated)
+}
+
+// UnsetConfigValue removes key from the configuration file at path and
+// reports whether it was there
+func UnsetConfigValue(path, key string) (bool, error) {
+	key = strings.ToLower(key)
+	lines, err := readConfigLines(path)
+	if err != nil {
+		return false, err
+	}
+
+	var kept []string
+	for _, line := range lines {
+		if configLineKey(line) != key {
+			kept = append(kept, line)
+		}
+	}
+	if len(kept) == len(lines) {
+		return false, nil
+	}
+	return true, writeConfigLines(path, kept)
+}
+
+// configLineKey returns the lowercased key a line sets, or "" for blank
+// lines and comments
+func configLineKey(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+		return ""
+	}
+	key, _, _ := strings.Cut(line, "=")
+	return strings.ToLower(strings.TrimSpace(key))
+}
+
+func readConfigLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	content := strings.TrimRight(string(data), "\n")
+	if content == "" {
+		return nil, nil
+	}
+	return strings.Split(content, "\n"), nil
+}
+
+func writeConfigLines(path string, lines []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	content := strings.Join(lines, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
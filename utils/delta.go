@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Deltas describe a target buffer as a sequence of instructions against a
+// base buffer: copy a range of the base, or insert literal bytes.
+//
+//	delta   = uvarint(len(base)) uvarint(len(target)) instruction*
+//	copy    = 0x01 uvarint(offset) uvarint(length)
+//	insert  = 0x00 uvarint(length) bytes
+
+const (
+	deltaInsert byte = 0x00
+	deltaCopy   byte = 0x01
+
+	// deltaBlock is the size of the chunks used to find matches in the base
+	deltaBlock = 16
+)
+
+// ComputeDelta returns a delta that rebuilds target from base
+func ComputeDelta(base, target []byte) []byte {
+	var out bytes.Buffer
+	out.Write(binary.AppendUvarint(nil, uint64(len(base))))
+	out.Write(binary.AppendUvarint(nil, uint64(len(target))))
+
+	// Index every block-aligned chunk of the base by its content
+	blocks := make(map[string]int)
+	for offset := 0; offset+deltaBlock <= len(base); offset += deltaBlock {
+		chunk := string(base[offset : offset+deltaBlock])
+		if _, ok := blocks[chunk]; !ok {
+			blocks[chunk] = offset
+		}
+	}
+
+	var pending []byte
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		out.WriteByte(deltaInsert)
+		out.Write(binary.AppendUvarint(nil, uint64(len(pending))))
+		out.Write(pending)
+		pending = nil
+	}
+
+	for i := 0; i < len(target); {
+		if i+deltaBlock <= len(target) {
+			if offset, ok := blocks[string(target[i:i+deltaBlock])]; ok {
+				length := deltaBlock
+				for offset+length < len(base) && i+length < len(target) && base[offset+length] == target[i+length] {
+					length++
+				}
+
+				flush()
+				out.WriteByte(deltaCopy)
+				out.Write(binary.AppendUvarint(nil, uint64(offset)))
+				out.Write(binary.AppendUvarint(nil, uint64(length)))
+				i += length
+				continue
+			}
+		}
+
+		pending = append(pending, target[i])
+		i++
+	}
+	flush()
+
+	return out.Bytes()
+}
+
+// ApplyDelta rebuilds the target buffer from base and a delta made by ComputeDelta
+func ApplyDelta(base, delta []byte) ([]byte, error) {
+	reader := bytes.NewReader(delta)
+
+	baseSize, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("malformed delta header: %w", err)
+	}
+	if baseSize != uint64(len(base)) {
+		return nil, fmt.Errorf("delta expects a %d byte base, got %d", baseSize, len(base))
+	}
+	targetSize, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("malformed delta header: %w", err)
+	}
+
+	target := make([]byte, 0, targetSize)
+	for reader.Len() > 0 {
+		op, _ := reader.ReadByte()
+		switch op {
+		case deltaCopy:
+			offset, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return nil, fmt.Errorf("malformed delta copy: %w", err)
+			}
+			length, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return nil, fmt.Errorf("malformed delta copy: %w", err)
+			}
+			if offset+length > uint64(len(base)) {
+				return nil, fmt.Errorf("delta copy outside of base")
+			}
+			target = append(target, base[offset:offset+length]...)
+		case deltaInsert:
+			length, err := binary.ReadUvarint(reader)
+			if err != nil || length > uint64(reader.Len()) {
+				return nil, fmt.Errorf("malformed delta insert")
+			}
+			literal := make([]byte, length)
+			reader.Read(literal)
+			target = append(target, literal...)
+		default:
+			return nil, fmt.Errorf("unknown delta instruction %#x", op)
+		}
+	}
+
+	if uint64(len(target)) != targetSize {
+		return nil, fmt.Errorf("delta produced %d bytes, expected %d", len(target), targetSize)
+	}
+	return target, nil
+}
@@ -0,0 +1,430 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// EditOp is the kind of a single step in a line diff
+type EditOp int
+
+const (
+	EQUAL EditOp = iota
+	INSERT
+	DELETE
+)
+
+// Edit is one line of a diff. OldLine and NewLine are zero based indexes
+// into the old and new line slices; only the relevant side is set for
+// inserts and deletes.
+type Edit struct {
+	Op      EditOp
+	OldLine int
+	NewLine int
+	Text    string
+}
+
+// SplitLines splits content into lines, keeping each line's terminating
+// newline so that joining the lines gives back the original content
+func SplitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// IsBinary reports whether content looks like binary data rather than text
+func IsBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0
+}
+
+// DiffLines returns the shortest edit script turning a into b, computed with
+// the linear space variant of Myers' O(ND) algorithm
+func DiffLines(a, b []string) []Edit {
+	differ := &lineDiffer{a: a, b: b}
+	differ.compare(0, len(a), 0, len(b))
+	return differ.edits
+}
+
+// lineDiffer collects the edits turning a into b, in order
+type lineDiffer struct {
+	a, b  []string
+	edits []Edit
+}
+
+// compare appends the edits turning a[aLo:aHi] into b[bLo:bHi]. Rather than
+// keeping every intermediate state to trace the path back, it finds the
+// middle of the path and recurses on both halves.
+func (differ *lineDiffer) compare(aLo, aHi, bLo, bHi int) {
+	a, b := differ.a, differ.b
+
+	for aLo < aHi && bLo < bHi && a[aLo] == b[bLo] {
+		differ.edits = append(differ.edits, Edit{Op: EQUAL, OldLine: aLo, NewLine: bLo, Text: a[aLo]})
+		aLo++
+		bLo++
+	}
+	aEnd, bEnd := aHi, bHi
+	for aLo < aEnd && bLo < bEnd && a[aEnd-1] == b[bEnd-1] {
+		aEnd--
+		bEnd--
+	}
+
+	switch x, y, ok := differ.middle(aLo, aEnd, bLo, bEnd); {
+	case ok:
+		differ.compare(aLo, x, bLo, y)
+		differ.compare(x, aEnd, y, bEnd)
+	default:
+		// One side is empty or the two have no line in common
+		for x := aLo; x < aEnd; x++ {
+			differ.edits = append(differ.edits, Edit{Op: DELETE, OldLine: x, NewLine: bLo, Text: a[x]})
+		}
+		for y := bLo; y < bEnd; y++ {
+			differ.edits = append(differ.edits, Edit{Op: INSERT, OldLine: aEnd, NewLine: y, Text: b[y]})
+		}
+	}
+
+	for ; aEnd < aHi; aEnd, bEnd = aEnd+1, bEnd+1 {
+		differ.edits = append(differ.edits, Edit{Op: EQUAL, OldLine: aEnd, NewLine: bEnd, Text: a[aEnd]})
+	}
+}
+
+// middle runs the search forwards from the start and backwards from the end
+// of a[aLo:aHi] and b[bLo:bHi] until the two meet, and returns where they
+// did. It reports false if either side is empty or the two share no line,
+// when every line of a is simply replaced by every line of b.
+func (differ *lineDiffer) middle(aLo, aHi, bLo, bHi int) (int, int, bool) {
+	a, b := differ.a, differ.b
+	n, m := aHi-aLo, bHi-bLo
+	if n == 0 || m == 0 || !shareLine(a[aLo:aHi], b[bLo:bHi]) {
+		return 0, 0, false
+	}
+
+	// forward[k+offset] is the furthest x reached from the start on diagonal
+	// k = x - y, and backward[k+offset] the furthest distance reached from
+	// the end on diagonal k of the reversed sequences
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	// The paths meet on the forward side when the difference in lengths is
+	// odd and on the backward side when it is even
+	delta := n - m
+	odd := delta%2 != 0
+
+	// Diagonals that ran off the edge are not extended any further
+	kStart, kEnd, rStart, rEnd := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		for k := -d + kStart; k <= d-kEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[k-1+offset] < forward[k+1+offset]) {
+				x = forward[k+1+offset]
+			} else {
+				x = forward[k-1+offset] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[aLo+x] == b[bLo+y] {
+				x++
+				y++
+			}
+			forward[k+offset] = x
+
+			switch r := delta - k + offset; {
+			case x > n:
+				kEnd += 2
+			case y > m:
+				kStart += 2
+			case odd && r >= 0 && r < len(backward) && backward[r] != -1:
+				if x >= n-backward[r] {
+					return aLo + x, bLo + y, true
+				}
+			}
+		}
+
+		for k := -d + rStart; k <= d-rEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[k-1+offset] < backward[k+1+offset]) {
+				x = backward[k+1+offset]
+			} else {
+				x = backward[k-1+offset] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[aHi-x-1] == b[bHi-y-1] {
+				x++
+				y++
+			}
+			backward[k+offset] = x
+
+			switch f := delta - k + offset; {
+			case x > n:
+				rEnd += 2
+			case y > m:
+				rStart += 2
+			case !odd && f >= 0 && f < len(forward) && forward[f] != -1:
+				if fx := forward[f]; fx >= n-x {
+					return aLo + fx, bLo + fx - (delta - k), true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// shareLine reports whether any line appears in both a and b. Searching
+// for the middle of a path costs the most exactly when nothing is shared.
+func shareLine(a, b []string) bool {
+	seen := make(map[string]bool, len(a))
+	for _, line := range a {
+		seen[line] = true
+	}
+	for _, line := range b {
+		if seen[line] {
+			return true
+		}
+	}
+	return false
+}
+
+// change replaces base lines [start, end) with lines
+type change struct {
+	start, end int
+	lines      []string
+}
+
+// changesBetween groups the edits from base to other into replaced ranges
+func changesBetween(base, other []string) []change {
+	var changes []change
+	var current *change
+
+	for _, edit := range DiffLines(base, other) {
+		if edit.Op == EQUAL {
+			if current != nil {
+				changes = append(changes, *current)
+				current = nil
+			}
+			continue
+		}
+
+		if current == nil {
+			current = &change{start: edit.OldLine, end: edit.OldLine}
+		}
+		if edit.Op == DELETE {
+			current.end = edit.OldLine + 1
+		} else {
+			current.lines = append(current.lines, edit.Text)
+		}
+	}
+	if current != nil {
+		changes = append(changes, *current)
+	}
+	return changes
+}
+
+// overlaps reports whether two changes touch the same base lines. Two
+// insertions at the same position also overlap, since their order is unknown.
+func (c change) overlaps(start, end int) bool {
+	return c.start == start || (c.start < end && start < c.end)
+}
+
+// applyChanges rebuilds base lines [start, end) with the changes applied
+func applyChanges(base []string, start, end int, changes []change) []string {
+	var lines []string
+	position := start
+	for _, c := range changes {
+		lines = append(lines, base[position:c.start]...)
+		lines = append(lines, c.lines...)
+		position = c.end
+	}
+	return append(lines, base[position:end]...)
+}
+
+// MergeLabels names the two sides in conflict markers
+type MergeLabels struct {
+	Ours   string
+	Theirs string
+}
+
+// Merge3 performs a three-way merge of ours and theirs against their common
+// base. Changes made on only one side are taken as they are; overlapping
+// changes that differ are written between conflict markers. It reports
+// whether any conflict was found.
+func Merge3(base, ours, theirs []byte, labels MergeLabels) ([]byte, bool) {
+	baseLines := SplitLines(base)
+	ourChanges := changesBetween(baseLines, SplitLines(ours))
+	theirChanges := changesBetween(baseLines, SplitLines(theirs))
+
+	var out strings.Builder
+	conflict := false
+	position := 0
+	i, j := 0, 0
+
+	for i < len(ourChanges) || j < len(theirChanges) {
+		// Start a region at the earliest pending change
+		var ourRegion, theirRegion []change
+		var start, end int
+		if j >= len(theirChanges) || (i < len(ourChanges) && ourChanges[i].start <= theirChanges[j].start) {
+			start, end = ourChanges[i].start, ourChanges[i].end
+			ourRegion = append(ourRegion, ourChanges[i])
+			i++
+		} else {
+			start, end = theirChanges[j].start, theirChanges[j].end
+			theirRegion = append(theirRegion, theirChanges[j])
+			j++
+		}
+
+		// Grow the region until no pending change from either side overlaps it
+		for grew := true; grew; {
+			grew = false
+			for i < len(ourChanges) && ourChanges[i].overlaps(start, end) {
+				end = max(end, ourChanges[i].end)
+				ourRegion = append(ourRegion, ourChanges[i])
+				i++
+				grew = true
+			}
+			for j < len(theirChanges) && theirChanges[j].overlaps(start, end) {
+				end = max(end, theirChanges[j].end)
+				theirRegion = append(theirRegion, theirChanges[j])
+				j++
+				grew = true
+			}
+		}
+
+		out.WriteString(strings.Join(baseLines[position:start], ""))
+		position = end
+
+		ourLines := applyChanges(baseLines, start, end, ourRegion)
+		theirLines := applyChanges(baseLines, start, end, theirRegion)
+
+		switch {
+		case len(theirRegion) == 0:
+			out.WriteString(strings.Join(ourLines, ""))
+		case len(ourRegion) == 0:
+			out.WriteString(strings.Join(theirLines, ""))
+		case strings.Join(ourLines, "") == strings.Join(theirLines, ""):
+			out.WriteString(strings.Join(ourLines, ""))
+		default:
+			conflict = true
+			out.WriteString("<<<<<<< " + labels.Ours + "\n")
+			writeConflictSide(&out, ourLines)
+			out.WriteString("=======\n")
+			writeConflictSide(&out, theirLines)
+			out.WriteString(">>>>>>> " + labels.Theirs + "\n")
+		}
+	}
+
+	out.WriteString(strings.Join(baseLines[position:], ""))
+	return []byte(out.String()), conflict
+}
+
+// writeConflictSide writes one side of a conflict, making sure the marker
+// that follows starts on its own line
+func writeConflictSide(out *strings.Builder, lines []string) {
+	text := strings.Join(lines, "")
+	out.WriteString(text)
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		out.WriteString("\n")
+	}
+}
+
+// hunkRange formats one side of a hunk header. An empty range names the
+// line before it, and a single line range leaves out its length.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// UnifiedDiff formats the line differences between a and b as a unified
+// diff with the given number of context lines. It returns only the hunks,
+// without file headers, and "" when the contents are equal. A negative
+// context is taken as none.
+func UnifiedDiff(a, b []byte, context int) string {
+	context = max(context, 0)
+	edits := DiffLines(SplitLines(a), SplitLines(b))
+
+	var changes []int
+	for i, edit := range edits {
+		if edit.Op != EQUAL {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	for c := 0; c < len(changes); {
+		// A hunk keeps growing while the next change is close enough for
+		// their context lines to touch
+		start := max(0, changes[c]-context)
+		last := changes[c]
+		for c+1 < len(changes) && changes[c+1]-last <= 2*context+1 {
+			c++
+			last = changes[c]
+		}
+		c++
+		end := min(len(edits), last+context+1)
+
+		oldCount, newCount := 0, 0
+		for _, edit := range edits[start:end] {
+			if edit.Op != INSERT {
+				oldCount++
+			}
+			if edit.Op != DELETE {
+				newCount++
+			}
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(edits[start].OldLine, oldCount), hunkRange(edits[start].NewLine, newCount))
+		for _, edit := range edits[start:end] {
+			prefix := " "
+			switch edit.Op {
+			case INSERT:
+				prefix = "+"
+			case DELETE:
+				prefix = "-"
+			}
+
+			out.WriteString(prefix + edit.Text)
+			if !strings.HasSuffix(edit.Text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return out.String()
+}
+
+// Similarity scores how alike two contents are, from 0 for nothing in common
+// to 100 for identical, by the share of lines they have in common. Binary
+// contents are only similar when they are identical.
+func Similarity(a, b []byte) int {
+	if bytes.Equal(a, b) {
+		return 100
+	}
+	if IsBinary(a) || IsBinary(b) {
+		return 0
+	}
+
+	linesA, linesB := SplitLines(a), SplitLines(b)
+	common := 0
+	for _, edit := range DiffLines(linesA, linesB) {
+		if edit.Op == EQUAL {
+			common++
+		}
+	}
+	return common * 200 / (len(linesA) + len(linesB))
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	COMPRESSION_NONE = "none"
+	COMPRESSION_ZLIB = "zlib"
+
+	LAYOUT_FLAT    = "flat"
+	LAYOUT_SHARDED = "sharded"
+
+	// INDEX_STAGED indexes list only the files staged since the last save;
+	// INDEX_FULL indexes list every tracked file as text, and INDEX_BINARY
+	// ones list every tracked file in the binary format
+	INDEX_STAGED = "staged"
+	INDEX_FULL   = "full"
+	INDEX_BINARY = "binary"
+)
+
+// Format records how a repository stores its data on disk. Repositories
+// created before the format file existed have no file and use the oldest
+// formats: uncompressed objects in a single flat directory and an index of
+// staged files only.
+type Format struct {
+	Compression string
+	Layout      string
+	Index       string
+}
+
+// CurrentFormat is the format written by init and produced by migrate
+func CurrentFormat() Format {
+	return Format{Compression: COMPRESSION_ZLIB, Layout: LAYOUT_SHARDED, Index: INDEX_BINARY}
+}
+
+func formatPath() string {
+	return filepath.Join(DEFAULT_PATH, "format")
+}
+
+// ReadFormat loads the repository format from .microgit/format
+func ReadFormat() Format {
+	format := Format{Compression: COMPRESSION_NONE, Layout: LAYOUT_FLAT, Index: INDEX_STAGED}
+
+	file, err := os.Open(formatPath())
+	if err != nil {
+		return format
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+
+		switch strings.TrimSpace(key) {
+		case "compression":
+			format.Compression = strings.TrimSpace(value)
+		case "layout":
+			format.Layout = strings.TrimSpace(value)
+		case "index":
+			format.Index = strings.TrimSpace(value)
+		}
+	}
+	return format
+}
+
+// WriteFormat stores the repository format in .microgit/format
+func WriteFormat(format Format) error {
+	content := fmt.Sprintf("compression = %s\nlayout = %s\nindex = %s\n", format.Compression, format.Layout, format.Index)
+	return os.WriteFile(formatPath(), []byte(content), 0644)
+}
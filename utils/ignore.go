@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// IGNORE_FILE is the name of the per-directory ignore file
+const IGNORE_FILE = ".microgitignore"
+
+// IgnorePattern is one pattern of an ignore file, with the gitignore
+// meaning: a leading ! re-includes what earlier patterns excluded, a
+// trailing / matches only directories, a slash at the start or in the middle
+// anchors the pattern to the directory of the ignore file, and ** matches
+// any number of directories.
+type IgnorePattern struct {
+	// Source and Line locate the pattern; Text is the line as written
+	Source string
+	Line   int
+	Text   string
+	// Base is the directory the pattern is relative to, "" for the root
+	Base string
+
+	Negate   bool
+	DirOnly  bool
+	anchored bool
+	regex    *regexp.Regexp
+}
+
+// ParseIgnorePattern parses one line of an ignore file. It returns false for
+// blank lines and comments.
+func ParseIgnorePattern(line, base string) (IgnorePattern, bool) {
+	pattern := IgnorePattern{Base: base}
+
+	// Trailing spaces are dropped unless escaped with a backslash
+	text := strings.TrimRight(line, " \t\r")
+	if strings.HasSuffix(text, "\\") && len(text) < len(strings.TrimRight(line, "\r")) {
+		text += " "
+	}
+	if text == "" || strings.HasPrefix(text, "#") {
+		return IgnorePattern{}, false
+	}
+	pattern.Text = text
+
+	if strings.HasPrefix(text, "!") {
+		pattern.Negate = true
+		text = text[1:]
+	} else if strings.HasPrefix(text, "\\!") || strings.HasPrefix(text, "\\#") {
+		text = text[1:]
+	}
+
+	if strings.HasSuffix(text, "/") {
+		pattern.DirOnly = true
+		text = strings.TrimRight(text, "/")
+	}
+	if strings.Contains(text, "/") {
+		pattern.anchored = true
+		text = strings.TrimPrefix(text, "/")
+	}
+	if text == "" {
+		return IgnorePattern{}, false
+	}
+
+	regex, err := regexp.Compile("^" + globToRegexp(text) + "$")
+	if err != nil {
+		return IgnorePattern{}, false
+	}
+	pattern.regex = regex
+	return pattern, true
+}
+
+// setEscaper escapes the characters of a glob set that are special inside a
+// regular expression class
+var setEscaper = strings.NewReplacer("\\", "\\\\", "[", "\\[", "]", "\\]")
+
+// globToRegexp translates a gitignore glob into a regular expression
+func globToRegexp(glob string) string {
+	var out strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if !strings.HasPrefix(glob[i:], "**") {
+				out.WriteString("[^/]*")
+				continue
+			}
+			atStart := i == 0 || glob[i-1] == '/'
+			end := i + 2
+			for end < len(glob) && glob[end] == '*' {
+				end++
+			}
+			switch {
+			case atStart && end == len(glob):
+				// "**" or "dir/**": everything below
+				out.WriteString(".*")
+			case atStart && glob[end] == '/':
+				// "**/" or "a/**/b": any number of directories, even none
+				out.WriteString("(?:.*/)?")
+				end++
+			default:
+				// Elsewhere ** is just a *
+				out.WriteString("[^/]*")
+			}
+			i = end - 1
+
+		case '?':
+			out.WriteString("[^/]")
+
+		case '[':
+			closing := strings.IndexByte(glob[i+1:], ']')
+			if closing == 0 && i+2 < len(glob) {
+				// A ] right after [ is part of the set
+				if next := strings.IndexByte(glob[i+2:], ']'); next >= 0 {
+					closing = next + 1
+				} else {
+					closing = -1
+				}
+			}
+			if closing < 0 {
+				out.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			set := glob[i+1 : i+1+closing]
+			out.WriteString("[")
+			if strings.HasPrefix(set, "!") || strings.HasPrefix(set, "^") {
+				out.WriteString("^/")
+				set = set[1:]
+			}
+			out.WriteString(setEscaper.Replace(set))
+			out.WriteString("]")
+			i += closing + 1
+
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			out.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+
+		default:
+			out.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return out.String()
+}
+
+// Matches reports whether the pattern applies to a path relative to the
+// repository root. It does not look at the pattern's negation.
+func (pattern IgnorePattern) Matches(file string, isDir bool) bool {
+	if pattern.DirOnly && !isDir {
+		return false
+	}
+	if pattern.Base != "" {
+		rest, ok := strings.CutPrefix(file, pattern.Base+"/")
+		if !ok {
+			return false
+		}
+		file = rest
+	}
+	if !pattern.anchored {
+		file = path.Base(file)
+	}
+	return pattern.regex.MatchString(file)
+}
+
+// ReadIgnoreFile parses every pattern of an ignore file, relative to base.
+// A missing file has no patterns.
+func ReadIgnoreFile(source, base string) ([]IgnorePattern, error) {
+	file, err := os.Open(source)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []IgnorePattern
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		if pattern, ok := ParseIgnorePattern(scanner.Text(), base); ok {
+			pattern.Source, pattern.Line = source, number
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+// IgnoreRules is an ordered list of patterns where later ones take
+// precedence over earlier ones
+type IgnoreRules []IgnorePattern
+
+// Match returns the last pattern matching the path, or nil. The path is
+// ignored if that pattern is not negated.
+func (rules IgnoreRules) Match(file string, isDir bool) *IgnorePattern {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].Matches(file, isDir) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
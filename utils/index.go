@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The index lists every tracked file with the hash of the blob the next
+// save point will record for it.
+//
+//	index = "MGIN" uint32(version) uint32(count) entry* sha256(previous bytes)
+//	entry = uvarint(len(path)) path hash[32]
+//
+// Entries are sorted by path and no path appears twice. Paths are stored
+// with their length, so they may contain any character.
+//
+// Older repositories keep the index as text, one "path hash" line per file.
+// ReadIndex still understands it; WriteIndex always writes the binary form.
+
+const (
+	stagingMagic   = "MGIN"
+	stagingVersion = 1
+)
+
+// IndexPath returns the location of the index
+func IndexPath() string {
+	return filepath.Join(DEFAULT_PATH, "index")
+}
+
+// EncodeIndex serialises path -> blob hash entries in the binary format
+func EncodeIndex(entries map[string]string) ([]byte, error) {
+	paths := make([]string, 0, len(entries))
+	for path := range entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var index bytes.Buffer
+	index.WriteString(stagingMagic)
+	binary.Write(&index, binary.BigEndian, uint32(stagingVersion))
+	binary.Write(&index, binary.BigEndian, uint32(len(paths)))
+	for _, path := range paths {
+		if path == "" {
+			return nil, fmt.Errorf("index entry with an empty path")
+		}
+		raw, err := hex.DecodeString(entries[path])
+		if err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("index entry %q has an invalid hash %q", path, entries[path])
+		}
+		index.Write(binary.AppendUvarint(nil, uint64(len(path))))
+		index.WriteString(path)
+		index.Write(raw)
+	}
+
+	checksum := sha256.Sum256(index.Bytes())
+	index.Write(checksum[:])
+	return index.Bytes(), nil
+}
+
+// DecodeIndex parses an index in either the binary or the older text format
+func DecodeIndex(data []byte) (map[string]string, error) {
+	if !bytes.HasPrefix(data, []byte(stagingMagic)) {
+		return decodeTextIndex(data), nil
+	}
+
+	if len(data) < 12+sha256.Size {
+		return nil, fmt.Errorf("index is truncated")
+	}
+	body := data[:len(data)-sha256.Size]
+	if checksum := sha256.Sum256(body); !bytes.Equal(checksum[:], data[len(body):]) {
+		return nil, fmt.Errorf("index checksum mismatch")
+	}
+	if version := binary.BigEndian.Uint32(body[4:8]); version != stagingVersion {
+		return nil, fmt.Errorf("unsupported index version %d", version)
+	}
+	count := int(binary.BigEndian.Uint32(body[8:12]))
+
+	entries := make(map[string]string, count)
+	rest := body[12:]
+	previous := ""
+	for i := 0; i < count; i++ {
+		length, n := binary.Uvarint(rest)
+		if n <= 0 || uint64(len(rest)-n) < length+sha256.Size {
+			return nil, fmt.Errorf("index entry %d is truncated", i)
+		}
+		rest = rest[n:]
+		path := string(rest[:length])
+		if i > 0 && path <= previous {
+			return nil, fmt.Errorf("index entries are not sorted at %q", path)
+		}
+		entries[path] = hex.EncodeToString(rest[length : length+sha256.Size])
+		rest = rest[length+sha256.Size:]
+		previous = path
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("index has %d unexpected trailing bytes", len(rest))
+	}
+	return entries, nil
+}
+
+// decodeTextIndex parses the older "path hash" text format. The hash never
+// contains a space, so the path runs up to the last one.
+func decodeTextIndex(data []byte) map[string]string {
+	entries := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if separator := strings.LastIndex(line, " "); separator > 0 {
+			entries[line[:separator]] = line[separator+1:]
+		}
+	}
+	return entries
+}
+
+// IsBinaryIndex reports whether the index on disk is already in the binary
+// format. A missing index counts as binary, since it will be written as such.
+func IsBinaryIndex() bool {
+	data, err := os.ReadFile(IndexPath())
+	return err != nil || bytes.HasPrefix(data, []byte(stagingMagic))
+}
+
+// ReadIndex loads the index. A missing index is reported with an error that
+// satisfies os.IsNotExist, together with an empty map.
+func ReadIndex() (map[string]string, error) {
+	data, err := os.ReadFile(IndexPath())
+	if err != nil {
+		return make(map[string]string), err
+	}
+	return DecodeIndex(data)
+}
+
+// WriteIndex replaces the whole index with the given entries
+func WriteIndex(entries map[string]string) error {
+	data, err := EncodeIndex(entries)
+	if err != nil {
+		return err
+	}
+
+	// Write a temporary file first so a failed write never leaves a
+	// half-written index behind
+	temp := IndexPath() + ".lock"
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, IndexPath())
+}
@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ObjectPath returns where a loose object is stored: objects/ab/cdef... with
+// the first two characters of the hash naming a fan-out directory
+func ObjectPath(hash string) string {
+	if len(hash) <= 2 {
+		return flatObjectPath(hash)
+	}
+	return filepath.Join(DEFAULT_PATH, "objects", hash[:2], hash[2:])
+}
+
+// flatObjectPath is where repositories without fan-out directories keep objects
+func flatObjectPath(hash string) string {
+	return filepath.Join(DEFAULT_PATH, "objects", hash)
+}
+
+// findLooseObject returns the path of the loose object file for hash. The
+// flat location is checked too, so objects left behind by an interrupted
+// layout migration are still found.
+func findLooseObject(hash string) (string, error) {
+	if hash == "" || strings.ContainsAny(hash, `/\.`) {
+		return "", fmt.Errorf("invalid object name %q", hash)
+	}
+
+	objectPath := ObjectPath(hash)
+	if _, err := os.Stat(objectPath); err == nil {
+		return objectPath, nil
+	}
+
+	flatPath := flatObjectPath(hash)
+	if _, err := os.Stat(flatPath); err != nil {
+		return "", fmt.Errorf("object %s not found: %w", hash, os.ErrNotExist)
+	}
+	return flatPath, nil
+}
+
+// ListLooseObjects returns the hash of every loose object in the repository
+func ListLooseObjects() ([]string, error) {
+	objectsDir := filepath.Join(DEFAULT_PATH, "objects")
+	entries, err := os.ReadDir(objectsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() {
+			if !strings.Contains(name, ".") {
+				hashes = append(hashes, name)
+			}
+			continue
+		}
+		if len(name) != 2 {
+			continue
+		}
+
+		shard, err := os.ReadDir(filepath.Join(objectsDir, name))
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range shard {
+			if !object.IsDir() && !strings.Contains(object.Name(), ".") {
+				hashes = append(hashes, name+object.Name())
+			}
+		}
+	}
+	return hashes, nil
+}
+
+// ShardObjects moves objects stored directly in objects/ into their fan-out
+// directories and records the sharded layout. It returns the number of
+// objects moved.
+func ShardObjects() (int, error) {
+	objectsDir := filepath.Join(DEFAULT_PATH, "objects")
+	entries, err := os.ReadDir(objectsDir)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || len(name) <= 2 || strings.Contains(name, ".") {
+			continue
+		}
+
+		target := ObjectPath(name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return moved, err
+		}
+		if err := os.Rename(flatObjectPath(name), target); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+
+	format := ReadFormat()
+	format.Layout = LAYOUT_SHARDED
+	return moved, WriteFormat(format)
+}
+
+// CompressLooseObject rewrites the loose object for hash compressed if it is
+// stored uncompressed. It reports whether the object was rewritten.
+func CompressLooseObject(hash string) (bool, error) {
+	objectPath, err := findLooseObject(hash)
+	if err != nil {
+		return false, err
+	}
+
+	data, err := os.ReadFile(objectPath)
+	if err != nil {
+		return false, err
+	}
+	if isCompressed(data) && HashContent(data) != hash {
+		return false, nil
+	}
+
+	if _, _, err := decodeStoredObject(hash, data); err != nil {
+		return false, fmt.Errorf("corrupt object %s: %w", hash, err)
+	}
+
+	compressed, err := compressObject(data)
+	if err != nil {
+		return false, err
+	}
+
+	// Write to a temporary file first so an interrupted migration never
+	// leaves a truncated object behind
+	tempPath := objectPath + ".tmp"
+	if err := os.WriteFile(tempPath, compressed, 0644); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tempPath, objectPath)
+}
+
+// UnpackObject writes a loose copy of an object that is only stored in a
+// pack, dated modified so it keeps ageing from then rather than from now
+func UnpackObject(hash string, modified time.Time) error {
+	data, err := readEncodedObject(hash)
+	if err != nil {
+		return err
+	}
+	if ReadFormat().Compression == COMPRESSION_ZLIB {
+		if data, err = compressObject(data); err != nil {
+			return err
+		}
+	}
+
+	objectPath := ObjectPath(hash)
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(objectPath, data); err != nil {
+		return err
+	}
+	return os.Chtimes(objectPath, modified, modified)
+}
+
+// LooseObjectInfo returns file information for the loose copy of an object
+func LooseObjectInfo(hash string) (os.FileInfo, error) {
+	objectPath, err := findLooseObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(objectPath)
+}
+
+// VerifyLooseObject re-hashes the loose copy of an object and checks that it
+// matches the name it is stored under
+func VerifyLooseObject(hash string) error {
+	objectPath, err := findLooseObject(hash)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(objectPath)
+	if err != nil {
+		return err
+	}
+	data, err = inflateStoredObject(hash, data)
+	if err != nil {
+		return fmt.Errorf("cannot decompress: %w", err)
+	}
+	if _, _, err := decodeStoredObject(hash, data); err != nil {
+		return err
+	}
+	if actual := HashContent(data); actual != hash {
+		return fmt.Errorf("content hashes to %s", actual)
+	}
+	return nil
+}
+
+// RemoveLooseObject deletes the loose copy of an object
+func RemoveLooseObject(hash string) error {
+	objectPath, err := findLooseObject(hash)
+	if err != nil {
+		return err
+	}
+	return os.Remove(objectPath)
+}
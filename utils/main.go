@@ -3,8 +3,6 @@ package utils
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"os"
-	"path/filepath"
 )
 
 const (
@@ -12,10 +10,60 @@ const (
 )
 
 type SavePoint struct {
-	Message   string            `json:"message"`
-	Timestamp string            `json:"timestamp"`
-	Parent    string            `json:"parent"`
-	Files     map[string]string `json:"files"`
+	Message   string `json:"message"`
+	Timestamp string `json:"timestamp"`
+	// Parent is the first parent. Save points written before merges existed
+	// store only this field.
+	Parent string `json:"parent,omitempty"`
+	// Parents lists every parent; merge save points have two
+	Parents []string `json:"parents,omitempty"`
+	// Tree is the hash of the root tree object describing the saved files
+	Tree string `json:"tree,omitempty"`
+	// Files is the flattened path -> blob hash view of Tree. It is only
+	// stored inline by save points written before trees were introduced.
+	Files map[string]string `json:"files,omitempty"`
+	// Complete is set on save points that record every tracked file. Older
+	// versions recorded only the files staged for each save.
+	Complete bool `json:"complete,omitempty"`
+	// The author wrote the changes; the committer made the save point.
+	// Save points written before identities were recorded have neither.
+	AuthorName     string `json:"author_name,omitempty"`
+	AuthorEmail    string `json:"author_email,omitempty"`
+	CommitterName  string `json:"committer_name,omitempty"`
+	CommitterEmail string `json:"committer_email,omitempty"`
+}
+
+// Author returns the author as "Name <email>", or "" if none was recorded
+func (savePoint SavePoint) Author() string {
+	return FormatIdentity(savePoint.AuthorName, savePoint.AuthorEmail)
+}
+
+// Committer returns the committer as "Name <email>", or "" if none was recorded
+func (savePoint SavePoint) Committer() string {
+	return FormatIdentity(savePoint.CommitterName, savePoint.CommitterEmail)
+}
+
+// FormatIdentity joins a name and email the way Git shows them
+func FormatIdentity(name, email string) string {
+	switch {
+	case email == "":
+		return name
+	case name == "":
+		return "<" + email + ">"
+	}
+	return name + " <" + email + ">"
+}
+
+// ParentHashes returns every parent of the save point, whichever format it
+// was stored in
+func (savePoint SavePoint) ParentHashes() []string {
+	if len(savePoint.Parents) > 0 {
+		return savePoint.Parents
+	}
+	if savePoint.Parent != "" {
+		return []string{savePoint.Parent}
+	}
+	return nil
 }
 
 // hashContent returns the SHA-256 hash of the file content
@@ -24,9 +72,3 @@ func HashContent(content []byte) string {
 	hasher.Write(content)
 	return hex.EncodeToString(hasher.Sum(nil))
 }
-
-// writeObject saves the file content to objects/<hash>
-func WriteObject(hash string, content []byte) error {
-	objectPath := filepath.Join(DEFAULT_PATH, "objects", hash)
-	return os.WriteFile(objectPath, content, 0644)
-}
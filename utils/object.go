@@ -0,0 +1,309 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ObjectKind identifies what an object in the object store contains
+type ObjectKind string
+
+const (
+	BLOB_OBJECT      ObjectKind = "blob"
+	TREE_OBJECT      ObjectKind = "tree"
+	SAVEPOINT_OBJECT ObjectKind = "savepoint"
+	TAG_OBJECT       ObjectKind = "tag"
+)
+
+func (kind ObjectKind) valid() bool {
+	switch kind {
+	case BLOB_OBJECT, TREE_OBJECT, SAVEPOINT_OBJECT, TAG_OBJECT:
+		return true
+	}
+	return false
+}
+
+// encodeObject prefixes the content with its "<kind> <length>\0" header
+func encodeObject(kind ObjectKind, content []byte) []byte {
+	header := fmt.Sprintf("%s %d\x00", kind, len(content))
+	return append([]byte(header), content...)
+}
+
+// decodeObject splits stored object bytes into kind and payload
+func decodeObject(data []byte) (ObjectKind, []byte, error) {
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		return "", nil, fmt.Errorf("object has no type header")
+	}
+
+	kindName, lengthText, ok := bytes.Cut(data[:end], []byte(" "))
+	if !ok {
+		return "", nil, fmt.Errorf("malformed object header %q", data[:end])
+	}
+
+	kind := ObjectKind(kindName)
+	if !kind.valid() {
+		return "", nil, fmt.Errorf("unknown object kind %q", kindName)
+	}
+
+	length, err := strconv.Atoi(string(lengthText))
+	if err != nil {
+		return "", nil, fmt.Errorf("malformed object length %q", lengthText)
+	}
+
+	content := data[end+1:]
+	if len(content) != length {
+		return "", nil, fmt.Errorf("object length mismatch: header says %d, found %d", length, len(content))
+	}
+	return kind, content, nil
+}
+
+// decodeStoredObject decodes the object stored under hash. Objects written
+// before type headers existed hold just their content and are named by its
+// hash; they are recognised by that hash and read as blobs, or as save
+// points if they hold save point JSON.
+func decodeStoredObject(hash string, data []byte) (ObjectKind, []byte, error) {
+	kind, content, err := decodeObject(data)
+	if err == nil {
+		return kind, content, nil
+	}
+	if HashContent(data) != hash {
+		return "", nil, err
+	}
+	if isLegacySavePoint(data) {
+		return SAVEPOINT_OBJECT, data, nil
+	}
+	return BLOB_OBJECT, data, nil
+}
+
+// isLegacySavePoint reports whether header-less object content is a save
+// point, which older versions stored as a bare JSON document
+func isLegacySavePoint(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.DisallowUnknownFields()
+	var savePoint SavePoint
+	if err := decoder.Decode(&savePoint); err != nil || decoder.More() {
+		return false
+	}
+	return savePoint.Timestamp != ""
+}
+
+// IsLegacyObject reports whether the object was written before type headers
+// existed
+func IsLegacyObject(hash string) (bool, error) {
+	data, err := readEncodedObject(hash)
+	if err != nil {
+		return false, err
+	}
+	if _, _, err := decodeObject(data); err == nil {
+		return false, nil
+	}
+	if _, _, err := decodeStoredObject(hash, data); err != nil {
+		return false, fmt.Errorf("corrupt object %s: %w", hash, err)
+	}
+	return true, nil
+}
+
+// UpgradeObject stores an object written before type headers existed in
+// the current encoding. It returns the hash the object is stored under
+// now, which differs from the old one whenever it was upgraded.
+func UpgradeObject(hash string) (string, error) {
+	legacy, err := IsLegacyObject(hash)
+	if err != nil {
+		return "", err
+	}
+	if !legacy {
+		return hash, nil
+	}
+
+	kind, content, err := ReadObject(hash)
+	if err != nil {
+		return "", err
+	}
+	return WriteObject(kind, content)
+}
+
+// compressObject zlib-compresses the encoded object bytes
+func compressObject(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := zlib.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// isCompressed reports whether stored object bytes are a zlib stream. Every
+// uncompressed object starts with its lowercase kind name, while zlib
+// streams written by compressObject start with 0x78 ('x').
+func isCompressed(data []byte) bool {
+	return len(data) > 1 && data[0] == 0x78 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0
+}
+
+// inflateObject returns the encoded object bytes, decompressing them if needed
+func inflateObject(data []byte) ([]byte, error) {
+	if !isCompressed(data) {
+		return data, nil
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// inflateStoredObject is inflateObject for the object stored under hash.
+// Content saved before type headers existed can start with bytes that look
+// like a zlib header, such as "x = 1"; such an object is recognised by its
+// raw bytes hashing to its name.
+func inflateStoredObject(hash string, data []byte) ([]byte, error) {
+	inflated, err := inflateObject(data)
+	if err != nil && HashContent(data) == hash {
+		return data, nil
+	}
+	return inflated, err
+}
+
+// HashObject returns the hash an object of the given kind and content is
+// stored under. The hash covers the header as well as the content.
+func HashObject(kind ObjectKind, content []byte) string {
+	return HashContent(encodeObject(kind, content))
+}
+
+// WriteObject saves the content as an object of the given kind under
+// objects/ and returns its hash
+func WriteObject(kind ObjectKind, content []byte) (string, error) {
+	data := encodeObject(kind, content)
+	hash := HashContent(data)
+
+	// Objects are content addressed, so an existing object never needs rewriting
+	if HasObject(hash) {
+		return hash, nil
+	}
+
+	objectPath := ObjectPath(hash)
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+		return "", err
+	}
+
+	if ReadFormat().Compression == COMPRESSION_ZLIB {
+		compressed, err := compressObject(data)
+		if err != nil {
+			return "", err
+		}
+		data = compressed
+	}
+
+	return hash, os.WriteFile(objectPath, data, 0644)
+}
+
+// HasObject reports whether the object exists, either loose or in a pack
+func HasObject(hash string) bool {
+	if _, err := findLooseObject(hash); err == nil {
+		return true
+	}
+	_, _, ok := findPackedObject(hash)
+	return ok
+}
+
+// FindObjects returns the sorted hashes of every object, loose or packed,
+// whose hash starts with prefix
+func FindObjects(prefix string) ([]string, error) {
+	found := make(map[string]bool)
+
+	loose, err := ListLooseObjects()
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range loose {
+		if strings.HasPrefix(hash, prefix) {
+			found[hash] = true
+		}
+	}
+
+	packs, err := ListPacks()
+	if err != nil {
+		return nil, err
+	}
+	for _, pack := range packs {
+		objects, err := ListPackObjects(pack)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range objects {
+			if strings.HasPrefix(object.Hash, prefix) {
+				found[object.Hash] = true
+			}
+		}
+	}
+
+	hashes := make([]string, 0, len(found))
+	for hash := range found {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	return hashes, nil
+}
+
+// readEncodedObject returns the uncompressed header and content of the
+// object, looking at loose objects first and then at every pack
+func readEncodedObject(hash string) ([]byte, error) {
+	objectPath, err := findLooseObject(hash)
+	if err != nil {
+		pack, offset, ok := findPackedObject(hash)
+		if !ok {
+			return nil, err
+		}
+
+		data, err := readPackEntry(pack, offset, 0)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt object %s in %s: %w", hash, pack, err)
+		}
+		return data, nil
+	}
+
+	data, err := os.ReadFile(objectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compression is detected per object so repositories keep working
+	// whether or not they have been migrated
+	data, err = inflateStoredObject(hash, data)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt object %s: %w", hash, err)
+	}
+	return data, nil
+}
+
+// ReadObject returns the kind and content of the object with the given hash
+func ReadObject(hash string) (ObjectKind, []byte, error) {
+	data, err := readEncodedObject(hash)
+	if err != nil {
+		return "", nil, err
+	}
+
+	kind, content, err := decodeStoredObject(hash, data)
+	if err != nil {
+		return "", nil, fmt.Errorf("corrupt object %s: %w", hash, err)
+	}
+	return kind, content, nil
+}
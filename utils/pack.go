@@ -0,0 +1,492 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A pack bundles many objects into one file. Objects are either stored whole
+// or as a delta against an earlier object in the same pack.
+//
+//	pack   = "MGPK" uint32(version) uint32(count) entry* sha256(previous bytes)
+//	entry  = byte(PACK_FULL) uvarint(size) zlib(object)
+//	       | byte(PACK_DELTA) uvarint(base offset) uvarint(size) zlib(delta)
+//
+// Every pack has an index listing its objects sorted by hash, so an object
+// is found with a binary search rather than a scan of the pack.
+//
+//	index  = "MGIX" uint32(version) uint32(count) uint32(fanout)[256] record*
+//	record = hash[32] uint64(offset)
+//
+// fanout[b] is the number of objects whose hash starts with a byte <= b.
+
+const (
+	PACK_FULL  byte = 1
+	PACK_DELTA byte = 2
+
+	packMagic   = "MGPK"
+	indexMagic  = "MGIX"
+	packVersion = 1
+
+	// packWindow is how many preceding objects are tried as delta bases
+	packWindow = 10
+	// maxDeltaDepth bounds how many deltas are applied to rebuild one object
+	maxDeltaDepth = 10
+
+	indexHeaderSize = 12 + 256*4
+	indexRecordSize = 32 + 8
+)
+
+// PackResult describes a pack written by WritePack
+type PackResult struct {
+	Name    string
+	Objects int
+	Deltas  int
+	Size    int64
+}
+
+type packEntry struct {
+	hash   string
+	kind   ObjectKind
+	data   []byte
+	base   int
+	delta  []byte
+	depth  int
+	offset uint64
+}
+
+func packDir() string {
+	return filepath.Join(DEFAULT_PATH, "objects", "pack")
+}
+
+// ListPacks returns the name (pack-<id>) of every pack in the repository
+func ListPacks() ([]string, error) {
+	entries, err := os.ReadDir(packDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var packs []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".idx"); ok {
+			packs = append(packs, name)
+		}
+	}
+	return packs, nil
+}
+
+// WritePack bundles the objects with the given hashes into a new pack,
+// delta-compressing objects against similar ones of the same kind
+func WritePack(hashes []string) (PackResult, error) {
+	seen := make(map[string]bool)
+	var entries []*packEntry
+	for _, hash := range hashes {
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		data, err := readEncodedObject(hash)
+		if err != nil {
+			return PackResult{}, err
+		}
+		kind, _, err := decodeStoredObject(hash, data)
+		if err != nil {
+			return PackResult{}, fmt.Errorf("corrupt object %s: %w", hash, err)
+		}
+		entries = append(entries, &packEntry{hash: hash, kind: kind, data: data, base: -1})
+	}
+
+	// Similar objects are usually of the same kind and close in size
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].kind != entries[j].kind {
+			return entries[i].kind < entries[j].kind
+		}
+		return len(entries[i].data) > len(entries[j].data)
+	})
+
+	result := PackResult{Objects: len(entries)}
+	for i, entry := range entries {
+		for j := max(0, i-packWindow); j < i; j++ {
+			candidate := entries[j]
+			if candidate.kind != entry.kind || candidate.depth >= maxDeltaDepth {
+				continue
+			}
+
+			delta := ComputeDelta(candidate.data, entry.data)
+			if len(delta) >= len(entry.data)/2 {
+				continue
+			}
+			if entry.delta == nil || len(delta) < len(entry.delta) {
+				entry.base = j
+				entry.delta = delta
+				entry.depth = candidate.depth + 1
+			}
+		}
+		if entry.delta != nil {
+			result.Deltas++
+		}
+	}
+
+	var pack bytes.Buffer
+	pack.WriteString(packMagic)
+	binary.Write(&pack, binary.BigEndian, uint32(packVersion))
+	binary.Write(&pack, binary.BigEndian, uint32(len(entries)))
+
+	for _, entry := range entries {
+		entry.offset = uint64(pack.Len())
+
+		payload := entry.data
+		if entry.delta != nil {
+			pack.WriteByte(PACK_DELTA)
+			pack.Write(binary.AppendUvarint(nil, entries[entry.base].offset))
+			payload = entry.delta
+		} else {
+			pack.WriteByte(PACK_FULL)
+		}
+
+		compressed, err := compressObject(payload)
+		if err != nil {
+			return PackResult{}, err
+		}
+		pack.Write(binary.AppendUvarint(nil, uint64(len(compressed))))
+		pack.Write(compressed)
+	}
+
+	checksum := sha256.Sum256(pack.Bytes())
+	pack.Write(checksum[:])
+
+	// Index records sorted by hash
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].hash < entries[j].hash
+	})
+
+	var names strings.Builder
+	var fanout [256]uint32
+	var records bytes.Buffer
+	for _, entry := range entries {
+		raw, err := hex.DecodeString(entry.hash)
+		if err != nil || len(raw) != 32 {
+			return PackResult{}, fmt.Errorf("invalid object name %q", entry.hash)
+		}
+		fanout[raw[0]]++
+		records.Write(raw)
+		binary.Write(&records, binary.BigEndian, entry.offset)
+		names.WriteString(entry.hash)
+	}
+	for b := 1; b < 256; b++ {
+		fanout[b] += fanout[b-1]
+	}
+
+	var index bytes.Buffer
+	index.WriteString(indexMagic)
+	binary.Write(&index, binary.BigEndian, uint32(packVersion))
+	binary.Write(&index, binary.BigEndian, uint32(len(entries)))
+	binary.Write(&index, binary.BigEndian, fanout)
+	index.Write(records.Bytes())
+
+	result.Name = "pack-" + HashContent([]byte(names.String()))
+	result.Size = int64(pack.Len())
+
+	if err := os.MkdirAll(packDir(), 0755); err != nil {
+		return PackResult{}, err
+	}
+
+	// The pack is written and read back under a temporary name, so a pack
+	// that fails never replaces an existing one with the same objects
+	tempName := "tmp-" + result.Name
+	tempPath := filepath.Join(packDir(), tempName+".pack")
+	if err := os.WriteFile(tempPath, pack.Bytes(), 0644); err != nil {
+		return PackResult{}, err
+	}
+
+	// Read every object back so callers can safely drop their loose copies
+	for _, entry := range entries {
+		data, err := readPackEntry(tempName, entry.offset, 0)
+		if err != nil || HashContent(data) != entry.hash {
+			os.Remove(tempPath)
+			return PackResult{}, fmt.Errorf("object %s did not survive packing", entry.hash)
+		}
+	}
+
+	// The index is written last so readers never find an index without its pack
+	if err := os.Rename(tempPath, filepath.Join(packDir(), result.Name+".pack")); err != nil {
+		os.Remove(tempPath)
+		return PackResult{}, err
+	}
+	if err := writeFileAtomic(filepath.Join(packDir(), result.Name+".idx"), index.Bytes()); err != nil {
+		return PackResult{}, err
+	}
+
+	return result, nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// RemovePack deletes a pack and its index
+func RemovePack(name string) error {
+	// Remove the index first so the pack is never referenced while missing
+	if err := os.Remove(filepath.Join(packDir(), name+".idx")); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(packDir(), name+".pack"))
+}
+
+// PackedObject is one object listed in a pack index
+type PackedObject struct {
+	Hash string
+	// Size is the number of bytes the object takes up inside the pack
+	Size int64
+}
+
+// ListPackObjects returns every object in the named pack
+func ListPackObjects(name string) ([]PackedObject, error) {
+	data, err := os.ReadFile(filepath.Join(packDir(), name+".idx"))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < indexHeaderSize || string(data[:4]) != indexMagic {
+		return nil, fmt.Errorf("invalid pack index %s", name)
+	}
+
+	count := int(binary.BigEndian.Uint32(data[8:12]))
+	if len(data) < indexHeaderSize+count*indexRecordSize {
+		return nil, fmt.Errorf("truncated pack index %s", name)
+	}
+
+	info, err := PackInfo(name)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]PackedObject, 0, count)
+	offsets := make([]uint64, 0, count)
+	for i := 0; i < count; i++ {
+		start := indexHeaderSize + i*indexRecordSize
+		objects = append(objects, PackedObject{Hash: hex.EncodeToString(data[start : start+32])})
+		offsets = append(offsets, binary.BigEndian.Uint64(data[start+32:start+indexRecordSize]))
+	}
+
+	// An entry runs until the next entry, or until the trailing checksum
+	sorted := append([]uint64(nil), offsets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i, offset := range offsets {
+		next := sort.Search(len(sorted), func(j int) bool { return sorted[j] > offset })
+		end := uint64(info.Size() - sha256.Size)
+		if next < len(sorted) {
+			end = sorted[next]
+		}
+		objects[i].Size = int64(end - offset)
+	}
+	return objects, nil
+}
+
+// VerifyPack checks the pack checksum and re-hashes every object in it. It
+// returns the problem found for each corrupt object it can list, and an
+// error if the pack or its index is damaged as a whole. Objects are still
+// checked one by one when only the pack checksum is wrong.
+func VerifyPack(name string) (map[string]error, error) {
+	var packErr error
+	data, err := os.ReadFile(filepath.Join(packDir(), name+".pack"))
+	switch {
+	case err != nil:
+		packErr = err
+	case len(data) < sha256.Size || string(data[:4]) != packMagic:
+		packErr = fmt.Errorf("invalid pack %s", name)
+	default:
+		body := data[:len(data)-sha256.Size]
+		if checksum := sha256.Sum256(body); !bytes.Equal(checksum[:], data[len(body):]) {
+			packErr = fmt.Errorf("pack %s checksum mismatch", name)
+		}
+	}
+
+	// Without a usable index the objects cannot even be listed
+	indexData, err := os.ReadFile(filepath.Join(packDir(), name+".idx"))
+	count := 0
+	switch {
+	case err != nil:
+	case len(indexData) < indexHeaderSize || string(indexData[:4]) != indexMagic:
+		err = fmt.Errorf("invalid pack index %s", name)
+	default:
+		count = int(binary.BigEndian.Uint32(indexData[8:12]))
+		if len(indexData) < indexHeaderSize+count*indexRecordSize {
+			err = fmt.Errorf("truncated pack index %s", name)
+		}
+	}
+	if err != nil {
+		return nil, errors.Join(packErr, err)
+	}
+
+	problems := make(map[string]error)
+	for i := 0; i < count; i++ {
+		start := indexHeaderSize + i*indexRecordSize
+		hash := hex.EncodeToString(indexData[start : start+32])
+		offset := binary.BigEndian.Uint64(indexData[start+32 : start+indexRecordSize])
+
+		object, err := readPackEntry(name, offset, 0)
+		if err != nil {
+			problems[hash] = err
+			continue
+		}
+		if _, _, err := decodeStoredObject(hash, object); err != nil {
+			problems[hash] = err
+			continue
+		}
+		if actual := HashContent(object); actual != hash {
+			problems[hash] = fmt.Errorf("content hashes to %s", actual)
+		}
+	}
+	return problems, packErr
+}
+
+// PackInfo returns file information for the named pack
+func PackInfo(name string) (os.FileInfo, error) {
+	return os.Stat(filepath.Join(packDir(), name+".pack"))
+}
+
+// findPackedObject looks the hash up in every pack index and returns the
+// pack holding it and the object's offset within that pack
+func findPackedObject(hash string) (string, uint64, bool) {
+	raw, err := hex.DecodeString(hash)
+	if err != nil || len(raw) != 32 {
+		return "", 0, false
+	}
+
+	packs, err := ListPacks()
+	if err != nil {
+		return "", 0, false
+	}
+
+	for _, name := range packs {
+		if offset, ok := lookupPackIndex(name, raw); ok {
+			return name, offset, true
+		}
+	}
+	return "", 0, false
+}
+
+// lookupPackIndex binary searches a pack index for the raw hash
+func lookupPackIndex(name string, raw []byte) (uint64, bool) {
+	file, err := os.Open(filepath.Join(packDir(), name+".idx"))
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	header := make([]byte, indexHeaderSize)
+	if _, err := io.ReadFull(file, header); err != nil || string(header[:4]) != indexMagic {
+		return 0, false
+	}
+
+	// The fanout table narrows the search to hashes sharing the first byte
+	fanout := func(b int) int {
+		if b < 0 {
+			return 0
+		}
+		return int(binary.BigEndian.Uint32(header[12+b*4:]))
+	}
+	low, high := fanout(int(raw[0])-1), fanout(int(raw[0]))
+
+	record := make([]byte, indexRecordSize)
+	for low < high {
+		mid := (low + high) / 2
+		if _, err := file.ReadAt(record, int64(indexHeaderSize+mid*indexRecordSize)); err != nil {
+			return 0, false
+		}
+
+		switch bytes.Compare(record[:32], raw) {
+		case 0:
+			return binary.BigEndian.Uint64(record[32:]), true
+		case -1:
+			low = mid + 1
+		default:
+			high = mid
+		}
+	}
+	return 0, false
+}
+
+// readPackEntry returns the encoded object stored at offset in the named
+// pack, resolving deltas against their bases
+func readPackEntry(name string, offset uint64, depth int) ([]byte, error) {
+	if depth > maxDeltaDepth {
+		return nil, fmt.Errorf("delta chain too deep in %s", name)
+	}
+
+	file, err := os.Open(filepath.Join(packDir(), name+".pack"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(int64(offset), io.SeekStart); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(file)
+
+	entryType, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	var baseOffset uint64
+	if entryType == PACK_DELTA {
+		if baseOffset, err = binary.ReadUvarint(reader); err != nil {
+			return nil, err
+		}
+	} else if entryType != PACK_FULL {
+		return nil, fmt.Errorf("unknown pack entry type %d in %s", entryType, name)
+	}
+
+	size, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := file.Stat(); err != nil || size > uint64(info.Size()) {
+		return nil, fmt.Errorf("truncated pack entry in %s", name)
+	}
+	compressed := make([]byte, size)
+	if _, err := io.ReadFull(reader, compressed); err != nil {
+		return nil, err
+	}
+
+	inflater, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer inflater.Close()
+	payload, err := io.ReadAll(inflater)
+	if err != nil {
+		return nil, err
+	}
+
+	if entryType == PACK_FULL {
+		return payload, nil
+	}
+
+	base, err := readPackEntry(name, baseOffset, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyDelta(base, payload)
+}
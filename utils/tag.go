@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Tag is an annotated tag: a named, dated note attached to a save point
+type Tag struct {
+	Name      string `json:"name"`
+	Target    string `json:"target"`
+	Tagger    string `json:"tagger"`
+	Timestamp string `json:"timestamp"`
+	Message   string `json:"message"`
+}
+
+// WriteTag stores an annotated tag object and returns its hash
+func WriteTag(tag Tag) (string, error) {
+	data, err := json.MarshalIndent(tag, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return WriteObject(TAG_OBJECT, data)
+}
+
+// ReadTag loads and parses the tag object with the given hash
+func ReadTag(hash string) (Tag, error) {
+	kind, data, err := ReadObject(hash)
+	if err != nil {
+		return Tag{}, fmt.Errorf("could not read tag object %s: %w", hash, err)
+	}
+	if kind != TAG_OBJECT {
+		return Tag{}, fmt.Errorf("object %s is a %s, not a tag", hash, kind)
+	}
+
+	var tag Tag
+	if err := json.Unmarshal(data, &tag); err != nil {
+		return Tag{}, fmt.Errorf("failed to parse tag %s: %w", hash, err)
+	}
+	return tag, nil
+}
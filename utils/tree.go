@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	FILE_MODE = "100644"
+	DIR_MODE  = "040000"
+)
+
+// TreeEntry is a single child of a tree: either a file blob or a subdirectory tree
+type TreeEntry struct {
+	Name string `json:"name"`
+	Mode string `json:"mode"`
+	Hash string `json:"hash"`
+}
+
+// Tree lists the contents of one directory, sorted by name
+type Tree struct {
+	Entries []TreeEntry `json:"entries"`
+}
+
+type treeNode struct {
+	files map[string]string
+	dirs  map[string]*treeNode
+}
+
+func newTreeNode() *treeNode {
+	return &treeNode{files: map[string]string{}, dirs: map[string]*treeNode{}}
+}
+
+// WriteTree stores the path -> blob hash map as a hierarchy of tree objects
+// and returns the hash of the root tree. Directories whose contents did not
+// change hash to an existing object and are not written again.
+func WriteTree(files map[string]string) (string, error) {
+	root := newTreeNode()
+
+	for filePath, hash := range files {
+		clean := path.Clean(filepath.ToSlash(filePath))
+		if clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return "", fmt.Errorf("path %q is outside the repository", filePath)
+		}
+
+		parts := strings.Split(clean, "/")
+		node := root
+		for _, dir := range parts[:len(parts)-1] {
+			if _, ok := node.files[dir]; ok {
+				return "", fmt.Errorf("path %q conflicts with file %q", filePath, dir)
+			}
+			child, ok := node.dirs[dir]
+			if !ok {
+				child = newTreeNode()
+				node.dirs[dir] = child
+			}
+			node = child
+		}
+
+		name := parts[len(parts)-1]
+		if _, ok := node.dirs[name]; ok {
+			return "", fmt.Errorf("path %q conflicts with a directory", filePath)
+		}
+		node.files[name] = hash
+	}
+
+	return writeTreeNode(root)
+}
+
+func writeTreeNode(node *treeNode) (string, error) {
+	tree := Tree{Entries: []TreeEntry{}}
+
+	for name, hash := range node.files {
+		tree.Entries = append(tree.Entries, TreeEntry{Name: name, Mode: FILE_MODE, Hash: hash})
+	}
+
+	for name, child := range node.dirs {
+		hash, err := writeTreeNode(child)
+		if err != nil {
+			return "", err
+		}
+		tree.Entries = append(tree.Entries, TreeEntry{Name: name, Mode: DIR_MODE, Hash: hash})
+	}
+
+	sort.Slice(tree.Entries, func(i, j int) bool {
+		return tree.Entries[i].Name < tree.Entries[j].Name
+	})
+
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return "", err
+	}
+
+	return WriteObject(TREE_OBJECT, data)
+}
+
+// ReadTree loads and parses the tree object with the given hash
+func ReadTree(hash string) (Tree, error) {
+	kind, data, err := ReadObject(hash)
+	if err != nil {
+		return Tree{}, fmt.Errorf("could not read tree object %s: %w", hash, err)
+	}
+	if kind != TREE_OBJECT {
+		return Tree{}, fmt.Errorf("object %s is a %s, not a tree", hash, kind)
+	}
+
+	var tree Tree
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return Tree{}, fmt.Errorf("failed to parse tree %s: %w", hash, err)
+	}
+	return tree, nil
+}
+
+// FlattenTree walks the tree with the given hash and returns every file it
+// contains as a slash separated path -> blob hash map
+func FlattenTree(hash string) (map[string]string, error) {
+	files := make(map[string]string)
+	if err := flattenInto(files, "", hash); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func flattenInto(files map[string]string, prefix, hash string) error {
+	tree, err := ReadTree(hash)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range tree.Entries {
+		entryPath := path.Join(prefix, entry.Name)
+		if entry.Mode == DIR_MODE {
+			if err := flattenInto(files, entryPath, entry.Hash); err != nil {
+				return err
+			}
+			continue
+		}
+		files[entryPath] = entry.Hash
+	}
+	return nil
+}